@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Generated template content is wrapped in marker comments so that later runs
+// can find and replace it without touching anything else in the file:
+//
+//	# >>> getignore: Go base=0123456789abcdef
+//	...template content...
+//	# <<< getignore: Go
+//
+// The optional base attribute identifies the snapshot of the content that was
+// originally written, which is used to merge local edits on upgrade. The
+// optional under attribute records the directory the template was rebased
+// under (see RebaseRules).
+const (
+	blockStartMarker = "# >>> getignore: "
+	blockEndMarker   = "# <<< getignore: "
+)
+
+// Block is a managed template block inside a gitignore file
+type Block struct {
+	Name    string
+	Content string
+	// Base is the hash of the content originally written to the block
+	Base string
+	// Under is the directory the template's rules were rebased under, or ""
+	Under string
+}
+
+// GitignoreFile is a gitignore file split into managed blocks and the
+// unmanaged text around them
+type GitignoreFile struct {
+	parts []filePart
+}
+
+// filePart is either a run of unmanaged text or a managed block
+type filePart struct {
+	text  string
+	block *Block
+}
+
+// ParseGitignoreFile splits gitignore content into managed blocks and
+// unmanaged text. Unmanaged text is kept byte for byte.
+func ParseGitignoreFile(content string) (*GitignoreFile, error) {
+	file := &GitignoreFile{}
+	var text strings.Builder
+	var current *Block
+	var blockContent strings.Builder
+
+	for i, line := range strings.SplitAfter(content, "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimRight(line, " \t\r\n")
+
+		if strings.HasPrefix(trimmed, blockStartMarker) {
+			if current != nil {
+				return nil, fmt.Errorf("line %d: block '%s' starts before block '%s' ends", i+1, blockName(trimmed, blockStartMarker), current.Name)
+			}
+			if text.Len() > 0 {
+				file.parts = append(file.parts, filePart{text: text.String()})
+				text.Reset()
+			}
+			current = parseBlockHeader(trimmed)
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, blockEndMarker) {
+			name := blockName(trimmed, blockEndMarker)
+			if current == nil || !strings.EqualFold(name, current.Name) {
+				return nil, fmt.Errorf("line %d: unexpected end of block '%s'", i+1, name)
+			}
+			current.Content = blockContent.String()
+			file.parts = append(file.parts, filePart{block: current})
+			blockContent.Reset()
+			current = nil
+			continue
+		}
+
+		if current != nil {
+			blockContent.WriteString(line)
+		} else {
+			text.WriteString(line)
+		}
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("block '%s' is never closed", current.Name)
+	}
+	if text.Len() > 0 {
+		file.parts = append(file.parts, filePart{text: text.String()})
+	}
+
+	return file, nil
+}
+
+// blockName returns the template name from a block marker line
+func blockName(line, marker string) string {
+	fields := strings.Fields(strings.TrimPrefix(line, marker))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// parseBlockHeader reads the template name and attributes from a start marker
+func parseBlockHeader(line string) *Block {
+	block := &Block{Name: blockName(line, blockStartMarker)}
+	fields := strings.Fields(strings.TrimPrefix(line, blockStartMarker))
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "base":
+			block.Base = value
+		case "under":
+			block.Under = value
+		}
+	}
+	return block
+}
+
+// header renders the start marker of the block
+func (b *Block) header() string {
+	header := blockStartMarker + b.Name
+	if b.Under != "" {
+		header += " under=" + b.Under
+	}
+	if b.Base != "" {
+		header += " base=" + b.Base
+	}
+	return header
+}
+
+// Label describes the block for messages, e.g. "Node (under web/)"
+func (b *Block) Label() string {
+	if b.Under == "" {
+		return b.Name
+	}
+	return b.Name + " (under " + b.Under + ")"
+}
+
+// Blocks returns the managed blocks in file order
+func (f *GitignoreFile) Blocks() []*Block {
+	var blocks []*Block
+	for _, part := range f.parts {
+		if part.block != nil {
+			blocks = append(blocks, part.block)
+		}
+	}
+	return blocks
+}
+
+// Block returns the managed block for the template rebased under the given
+// directory ("" for none), if there is one
+func (f *GitignoreFile) Block(name, under string) (*Block, bool) {
+	for _, block := range f.Blocks() {
+		if strings.EqualFold(block.Name, name) && block.Under == under {
+			return block, true
+		}
+	}
+	return nil, false
+}
+
+// Unmanaged returns all text outside managed blocks
+func (f *GitignoreFile) Unmanaged() string {
+	var builder strings.Builder
+	for _, part := range f.parts {
+		if part.block == nil {
+			builder.WriteString(part.text)
+		}
+	}
+	return builder.String()
+}
+
+// SetBlock replaces the content of the block for the template rebased under
+// the given directory, or appends a new block at the end of the file if
+// there is none yet. It returns the block.
+func (f *GitignoreFile) SetBlock(name, under, content string) *Block {
+	if block, ok := f.Block(name, under); ok {
+		block.Content = normalizeBlockContent(content)
+		return block
+	}
+
+	// Keep a blank line between the new block and whatever comes before it
+	if len(f.parts) > 0 {
+		last := &f.parts[len(f.parts)-1]
+		if last.block != nil {
+			f.parts = append(f.parts, filePart{text: "\n"})
+		} else if strings.TrimSpace(last.text) != "" {
+			if !strings.HasSuffix(last.text, "\n") {
+				last.text += "\n"
+			}
+			if !strings.HasSuffix(last.text, "\n\n") {
+				last.text += "\n"
+			}
+		}
+	}
+
+	block := &Block{Name: name, Under: under, Content: normalizeBlockContent(content)}
+	f.parts = append(f.parts, filePart{block: block})
+	return block
+}
+
+// String renders the file back to text
+func (f *GitignoreFile) String() string {
+	var builder strings.Builder
+	for _, part := range f.parts {
+		if part.block == nil {
+			builder.WriteString(part.text)
+			continue
+		}
+		builder.WriteString(part.block.header() + "\n")
+		builder.WriteString(part.block.Content)
+		builder.WriteString(blockEndMarker + part.block.Name + "\n")
+	}
+	return builder.String()
+}
+
+// blockLineNames returns, for every line of the content, the label of the
+// managed block the line belongs to, or "" for lines outside blocks. Marker
+// lines belong to their block.
+func blockLineNames(content string) []string {
+	var names []string
+	current := ""
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimRight(line, " \t\r")
+		switch {
+		case strings.HasPrefix(trimmed, blockStartMarker):
+			current = parseBlockHeader(trimmed).Label()
+			names = append(names, current)
+		case strings.HasPrefix(trimmed, blockEndMarker):
+			names = append(names, current)
+			current = ""
+		default:
+			names = append(names, current)
+		}
+	}
+	return names
+}
+
+// normalizeBlockContent drops trailing blank lines and makes sure non-empty
+// content ends with a newline, so the end marker is always on its own line
+func normalizeBlockContent(content string) string {
+	content = strings.TrimRight(content, "\r\n")
+	if content == "" {
+		return ""
+	}
+	return content + "\n"
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParseGitignoreFile tests splitting a file into managed blocks and unmanaged text
+func TestParseGitignoreFile(t *testing.T) {
+	content := "# My rules\n/secrets\n\n" +
+		"# >>> getignore: Go\n*.exe\n# <<< getignore: Go\n" +
+		"\n# More rules\n*.bak\n"
+
+	file, err := ParseGitignoreFile(content)
+	if err != nil {
+		t.Fatalf("ParseGitignoreFile returned error: %v", err)
+	}
+
+	blocks := file.Blocks()
+	if len(blocks) != 1 || blocks[0].Name != "Go" || blocks[0].Content != "*.exe\n" {
+		t.Fatalf("Unexpected blocks: %+v", blocks)
+	}
+
+	if file.String() != content {
+		t.Errorf("Round trip mismatch. Expected '%s', got '%s'", content, file.String())
+	}
+
+	expectedUnmanaged := "# My rules\n/secrets\n\n\n# More rules\n*.bak\n"
+	if file.Unmanaged() != expectedUnmanaged {
+		t.Errorf("Unmanaged text mismatch, got '%s'", file.Unmanaged())
+	}
+
+	file, err = ParseGitignoreFile("# >>> getignore: Go base=abc\n*.exe\n# <<< getignore: Go\n")
+	if err != nil {
+		t.Fatalf("ParseGitignoreFile returned error: %v", err)
+	}
+	if block, ok := file.Block("Go", ""); !ok || block.Base != "abc" {
+		t.Errorf("Expected block Go with base abc, got %+v", block)
+	}
+}
+
+// TestParseGitignoreFileErrors tests that broken markers are reported
+func TestParseGitignoreFileErrors(t *testing.T) {
+	broken := []string{
+		"# >>> getignore: Go\n*.exe\n",
+		"*.exe\n# <<< getignore: Go\n",
+		"# >>> getignore: Go\n# >>> getignore: Node\n",
+		"# >>> getignore: Go\n# <<< getignore: Node\n",
+	}
+
+	for _, content := range broken {
+		if _, err := ParseGitignoreFile(content); err == nil {
+			t.Errorf("Expected an error for '%s'", content)
+		}
+	}
+}
+
+// TestSetBlock tests replacing and appending managed blocks
+func TestSetBlock(t *testing.T) {
+	file, err := ParseGitignoreFile("/build\n# >>> getignore: Go\nold\n# <<< getignore: Go\n# mine\n")
+	if err != nil {
+		t.Fatalf("ParseGitignoreFile returned error: %v", err)
+	}
+
+	file.SetBlock("go", "", "new\n")
+	file.SetBlock("Node", "", "node_modules/\n")
+
+	expected := "/build\n# >>> getignore: Go\nnew\n# <<< getignore: Go\n# mine\n\n" +
+		"# >>> getignore: Node\nnode_modules/\n# <<< getignore: Node\n"
+	if file.String() != expected {
+		t.Errorf("SetBlock mismatch. Expected '%s', got '%s'", expected, file.String())
+	}
+}
+
+// TestWriteGitignoreIdempotent tests that writing the same templates twice
+// leaves the file unchanged and keeps user content outside the blocks
+func TestWriteGitignoreIdempotent(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-blocks-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
+
+	outputPath := filepath.Join(tempDir, ".gitignore")
+	err = ioutil.WriteFile(outputPath, []byte("# Project rules\n*.log\n"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	sections := []TemplateSection{{Name: "Go", Content: "*.exe\n*.log\n"}}
+	expected := "# Project rules\n*.log\n\n# >>> getignore: Go base=" + contentHash("*.exe\n") + "\n*.exe\n# <<< getignore: Go\n"
+
+	for i := 0; i < 2; i++ {
+		err = WriteGitignore(sections, outputPath, WriteMerge)
+		if err != nil {
+			t.Fatalf("WriteGitignore returned error: %v", err)
+		}
+
+		content, err := ioutil.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(content) != expected {
+			t.Errorf("Run %d: content mismatch. Expected '%s', got '%s'", i+1, expected, string(content))
+		}
+	}
+}
@@ -1,6 +1,9 @@
 @echo off
 echo Building gitignore CLI tool...
-go build -o gitignore.exe main.go
+setlocal enabledelayedexpansion
+set SOURCES=
+for %%f in (*.go) do set SOURCES=!SOURCES! %%f
+go build -o gitignore.exe !SOURCES!
 if %ERRORLEVEL% NEQ 0 (
     echo Build failed!
     exit /b %ERRORLEVEL%
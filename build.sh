@@ -1,6 +1,6 @@
 #!/bin/bash
 echo "Building gitignore CLI tool..."
-go build -o gitignore main.go
+go build -o gitignore *.go
 if [ $? -ne 0 ]; then
     echo "Build failed!"
     exit 1
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultCommand runs when the first word on the command line is not a
+// command name, so "gitignore Go" means "gitignore generate Go"
+const defaultCommand = "generate"
+
+// helpWidth is the width help text is wrapped to
+const helpWidth = 88
+
+// Exit codes of the program
+const (
+	exitError = 1
+	// exitUsage is returned for invalid command lines
+	exitUsage = 2
+	// exitCancelled is returned when the user declines a question
+	exitCancelled = 3
+	// exitNotIgnored is returned by check-ignore when no path is ignored,
+	// like git check-ignore
+	exitNotIgnored = 1
+	// exitFatal is returned by check-ignore for errors, as by git, since
+	// exitError means no path is ignored there
+	exitFatal = 128
+)
+
+// Flag is an option of a command, e.g. "-o, --output <file>"
+type Flag struct {
+	// Name is the long name, used as "--name"
+	Name string
+	// Short is an optional one letter name, used as "-n"
+	Short string
+	// Value names the flag's argument in help; flags without one are
+	// booleans
+	Value string
+	Usage string
+}
+
+// Command is a command of the CLI, e.g. "check-ignore" or "preset save"
+type Command struct {
+	Name string
+	// Args describes the positional arguments in help, e.g. "<path>..."
+	Args    string
+	Summary string
+	// Description is shown below the summary in the command's help
+	Description string
+	Flags       []Flag
+	// MinArgs and MaxArgs bound the number of positional arguments; a
+	// negative MaxArgs means there is no limit
+	MinArgs     int
+	MaxArgs     int
+	Subcommands []*Command
+	Run         func(inv *Invocation)
+}
+
+// Invocation is a parsed command line
+type Invocation struct {
+	Command *Command
+	// Path is the full command name, e.g. "preset save"
+	Path string
+	// Args are the positional arguments
+	Args     []string
+	flags    map[string][]string
+	commands []*Command
+}
+
+// globalFlags are accepted by every command
+var globalFlags = []Flag{
+	{Name: "help", Short: "h", Usage: "Show help for the command"},
+	{Name: "strict", Usage: "Fail when a template name is ambiguous instead of picking one by precedence"},
+	{Name: "yes", Short: "y", Usage: "Answer yes to every question, e.g. overwrite existing files without asking"},
+	{Name: "force", Usage: "Same as --yes"},
+	{Name: "no-input", Usage: "Never ask questions; fail instead when an answer is needed"},
+	{Name: "source", Value: "spec", Usage: "Where to get templates: github, dir:PATH, git:PATH[@REF] or tarball:URL"},
+	{Name: "registry", Value: "dir", Usage: "Team template directory, or git:PATH[@REF], whose templates shadow the user's and upstream ones"},
+	{Name: "api-url", Value: "url", Usage: "Base URL of the GitHub API to download templates from"},
+	{Name: "raw-url", Value: "url", Usage: "Base URL raw template files are downloaded from"},
+	{Name: "repo", Value: "owner/name", Usage: "Repository to download templates from"},
+	{Name: "branch", Value: "name", Usage: "Branch to download templates from"},
+}
+
+// stdinIsTerminal reports whether stdin is an interactive terminal rather
+// than a pipe, a file or /dev/null
+var stdinIsTerminal = func() bool {
+	return isTerminal(os.Stdin)
+}
+
+// isTerminal reports whether a file is a character device other than the
+// null device, which is one too but never has anyone typing into it
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// Bool reports whether a boolean flag was given
+func (inv *Invocation) Bool(name string) bool {
+	return len(inv.flags[name]) > 0
+}
+
+// String returns the last value given for a flag, or def if it was not given
+func (inv *Invocation) String(name, def string) string {
+	values := inv.flags[name]
+	if len(values) == 0 {
+		return def
+	}
+	return values[len(values)-1]
+}
+
+// Strings returns every value given for a flag, in order
+func (inv *Invocation) Strings(name string) []string {
+	return inv.flags[name]
+}
+
+// ParseCommandLine finds the command named by the leading words of args and
+// parses the rest against its flags and the global flags. Flags may appear
+// before, between and after the positional arguments; everything after "--"
+// is positional. When the first word is not a command, or "--" comes before
+// any word, the default command is used, so "gitignore -- list" generates a
+// template called "list".
+func ParseCommandLine(commands []*Command, args []string) (*Invocation, error) {
+	inv := &Invocation{flags: make(map[string][]string), commands: commands}
+
+	// Find the command, and any subcommands, among the words before "--"
+	rest := append([]string(nil), args...)
+	valueFlags := flagsTakingValues(commands)
+	candidates := commands
+	var path []string
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		if arg == "--" {
+			break
+		}
+		if isFlag(arg) {
+			if !strings.Contains(arg, "=") && valueFlags[arg] {
+				i++
+			}
+			continue
+		}
+
+		command := findCommand(candidates, arg)
+		if command == nil {
+			break
+		}
+		inv.Command = command
+		path = append(path, command.Name)
+		candidates = command.Subcommands
+		rest = append(rest[:i:i], rest[i+1:]...)
+		i--
+	}
+
+	explicit := inv.Command != nil
+	if !explicit {
+		inv.Command = findCommand(commands, defaultCommand)
+		path = []string{defaultCommand}
+	}
+	inv.Path = strings.Join(path, " ")
+
+	err := inv.parseFlags(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	// "gitignore --help" asks for the general help
+	if !explicit && len(inv.Args) == 0 && inv.Bool("help") {
+		inv.Command = findCommand(commands, "help")
+		inv.Path = "help"
+		return inv, nil
+	}
+
+	if inv.Bool("help") {
+		return inv, nil
+	}
+	if len(inv.Args) < inv.Command.MinArgs || (inv.Command.MaxArgs >= 0 && len(inv.Args) > inv.Command.MaxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments, usage: %s", inv.Usage())
+	}
+
+	return inv, nil
+}
+
+// parseFlags splits the arguments into flags and positional arguments
+func (inv *Invocation) parseFlags(args []string) error {
+	known := make(map[string]Flag)
+	for _, flag := range append(append([]Flag(nil), inv.Command.Flags...), globalFlags...) {
+		known["--"+flag.Name] = flag
+		if flag.Short != "" {
+			known["-"+flag.Short] = flag
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			inv.Args = append(inv.Args, args[i+1:]...)
+			break
+		}
+		if !isFlag(arg) {
+			inv.Args = append(inv.Args, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		flag, ok := known[name]
+		if !ok {
+			return fmt.Errorf("unknown flag '%s' for 'gitignore %s'", name, inv.Path)
+		}
+		switch {
+		case flag.Value == "" && hasValue:
+			return fmt.Errorf("flag '%s' does not take a value", name)
+		case flag.Value == "":
+			value = "true"
+		case !hasValue:
+			if i+1 >= len(args) {
+				return fmt.Errorf("flag '%s' requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		inv.flags[flag.Name] = append(inv.flags[flag.Name], value)
+	}
+
+	return nil
+}
+
+// AssumeYes reports whether questions should be answered with yes
+func (inv *Invocation) AssumeYes() bool {
+	return inv.Bool("yes") || inv.Bool("force")
+}
+
+// Ask prints a question and reads the answer from stdin, trimmed and in
+// lower case. It fails instead of asking when --no-input is given or stdin
+// is not a terminal, so scripts never hang on a question or have it
+// silently answered by their input.
+func (inv *Invocation) Ask(question string) (string, error) {
+	if inv.Bool("no-input") {
+		return "", fmt.Errorf("an answer is needed but --no-input was given")
+	}
+	if !stdinIsTerminal() {
+		return "", fmt.Errorf("an answer is needed but stdin is not a terminal")
+	}
+
+	fmt.Print(question)
+	response, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && response == "" {
+		fmt.Println()
+		return "", fmt.Errorf("no answer could be read: %v", err)
+	}
+	return strings.TrimSpace(strings.ToLower(response)), nil
+}
+
+// Usage returns the usage line of the invoked command
+func (inv *Invocation) Usage() string {
+	return commandUsage(inv.Path, inv.Command)
+}
+
+// commandUsage returns the usage line of a command
+func commandUsage(path string, command *Command) string {
+	usage := "gitignore " + path
+	if len(command.Subcommands) > 0 {
+		usage += " [command]"
+	}
+	usage += " [options]"
+	if command.Args != "" {
+		usage += " " + command.Args
+	}
+	return usage
+}
+
+// isFlag reports whether an argument is a flag rather than a positional
+// argument; a lone "-" is positional
+func isFlag(arg string) bool {
+	return strings.HasPrefix(arg, "-") && arg != "-"
+}
+
+// findCommand returns the command with the given name, ignoring case
+func findCommand(commands []*Command, name string) *Command {
+	for _, command := range commands {
+		if strings.EqualFold(command.Name, name) {
+			return command
+		}
+	}
+	return nil
+}
+
+// flagsTakingValues returns the spellings of every flag that takes a value,
+// across all commands, so their values are not mistaken for command names
+func flagsTakingValues(commands []*Command) map[string]bool {
+	spellings := make(map[string]bool)
+	add := func(flags []Flag) {
+		for _, flag := range flags {
+			if flag.Value == "" {
+				continue
+			}
+			spellings["--"+flag.Name] = true
+			if flag.Short != "" {
+				spellings["-"+flag.Short] = true
+			}
+		}
+	}
+	var walk func(commands []*Command)
+	walk = func(commands []*Command) {
+		for _, command := range commands {
+			add(command.Flags)
+			walk(command.Subcommands)
+		}
+	}
+	add(globalFlags)
+	walk(commands)
+	return spellings
+}
+
+// printHelp prints the general help with every command
+func printHelp(commands []*Command) {
+	fmt.Println("Gitignore Generator - A tool to create .gitignore files for your projects")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  gitignore <command> [arguments] [options]")
+	fmt.Println("  gitignore <template>... [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	for _, command := range commands {
+		name := command.Name
+		if command.Args != "" {
+			name += " " + command.Args
+		}
+		printHelpItem(name, command.Summary)
+	}
+	fmt.Println()
+	fmt.Println("GLOBAL OPTIONS:")
+	for _, flag := range globalFlags {
+		printHelpItem(flagLabel(flag), flag.Usage)
+	}
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  gitignore Go                 Create a .gitignore file for Go")
+	fmt.Println("  gitignore Python -o out.txt  Create a Python .gitignore file named out.txt")
+	fmt.Println("  gitignore Go Node Global/JetBrains")
+	fmt.Println("                               Combine several templates into one .gitignore")
+	fmt.Println("  gitignore -- list            Generate a template named like a command")
+	fmt.Println("  gitignore list               Show all available templates")
+	fmt.Println("  gitignore help check-ignore  Show the options of a command")
+	fmt.Println()
+	fmt.Println("The templates are stored in ~/.gitignore-cli directory. Template aliases such as")
+	fmt.Println("golang=Go can be added to ~/.gitignore-cli/config.json.")
+}
+
+// printCommandHelp prints the help of a single command
+func printCommandHelp(path string, command *Command) {
+	fmt.Printf("Usage: %s\n", commandUsage(path, command))
+	fmt.Println()
+	for _, line := range wrapText(command.Summary, helpWidth) {
+		fmt.Println(line)
+	}
+	if command.Description != "" {
+		fmt.Println()
+		for _, line := range wrapText(command.Description, helpWidth) {
+			fmt.Println(line)
+		}
+	}
+
+	if len(command.Subcommands) > 0 {
+		fmt.Println()
+		fmt.Println("COMMANDS:")
+		for _, subcommand := range command.Subcommands {
+			name := subcommand.Name
+			if subcommand.Args != "" {
+				name += " " + subcommand.Args
+			}
+			printHelpItem(name, subcommand.Summary)
+		}
+	}
+
+	if len(command.Flags) > 0 {
+		fmt.Println()
+		fmt.Println("OPTIONS:")
+		for _, flag := range command.Flags {
+			printHelpItem(flagLabel(flag), flag.Usage)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("GLOBAL OPTIONS:")
+	for _, flag := range globalFlags {
+		printHelpItem(flagLabel(flag), flag.Usage)
+	}
+}
+
+// flagLabel renders a flag for help, e.g. "-o, --output <file>"
+func flagLabel(flag Flag) string {
+	label := "--" + flag.Name
+	if flag.Short != "" {
+		label = "-" + flag.Short + ", " + label
+	}
+	if flag.Value != "" {
+		label += " <" + flag.Value + ">"
+	}
+	return label
+}
+
+// printHelpItem prints a name and its wrapped description in two columns
+func printHelpItem(name, text string) {
+	const column = 23
+	lines := wrapText(text, helpWidth-column)
+	if len(name) > column-3 || len(lines) == 0 {
+		fmt.Printf("  %s\n", name)
+	} else {
+		fmt.Printf("  %-*s%s\n", column-2, name, lines[0])
+		lines = lines[1:]
+	}
+	for _, line := range lines {
+		fmt.Printf("%*s%s\n", column, "", line)
+	}
+}
+
+// wrapText splits text into lines of at most width characters, breaking
+// between words
+func wrapText(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
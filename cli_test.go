@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestParseCommandLine tests finding the command and parsing its flags
+func TestParseCommandLine(t *testing.T) {
+	tests := []struct {
+		args  []string
+		path  string
+		flags map[string]string
+		rest  []string
+	}{
+		{[]string{"list"}, "list", nil, nil},
+		{[]string{"LIST"}, "list", nil, nil},
+		{[]string{"Go", "Node"}, "generate", nil, []string{"Go", "Node"}},
+		{[]string{"--", "list"}, "generate", nil, []string{"list"}},
+		{[]string{"--strict", "list"}, "list", map[string]string{"strict": "true"}, nil},
+		{[]string{"check-ignore", "-v", "a", "-f", "other", "b"}, "check-ignore", map[string]string{"verbose": "true", "file": "other"}, []string{"a", "b"}},
+		{[]string{"explain", "--file=x", "--", "-weird"}, "explain", map[string]string{"file": "x"}, []string{"-weird"}},
+		{[]string{"preset", "backend", "-o", "out"}, "preset", map[string]string{"output": "out"}, []string{"backend"}},
+		{[]string{"preset", "show", "backend"}, "preset show", nil, []string{"backend"}},
+		{[]string{"-o", "out", "auto"}, "auto", map[string]string{"output": "out"}, nil},
+		{[]string{"upgrade", "--help"}, "upgrade", map[string]string{"help": "true"}, nil},
+		{[]string{"--help"}, "help", map[string]string{"help": "true"}, nil},
+		{[]string{"help", "preset", "save"}, "help", nil, []string{"preset", "save"}},
+	}
+
+	for _, test := range tests {
+		inv, err := ParseCommandLine(commandTable(), test.args)
+		if err != nil {
+			t.Errorf("ParseCommandLine(%v) returned error: %v", test.args, err)
+			continue
+		}
+		if inv.Path != test.path {
+			t.Errorf("ParseCommandLine(%v) command = %q, expected %q", test.args, inv.Path, test.path)
+		}
+		for name, value := range test.flags {
+			if got := inv.String(name, ""); got != value {
+				t.Errorf("ParseCommandLine(%v) flag %s = %q, expected %q", test.args, name, got, value)
+			}
+		}
+		if strings.Join(inv.Args, ",") != strings.Join(test.rest, ",") {
+			t.Errorf("ParseCommandLine(%v) args = %v, expected %v", test.args, inv.Args, test.rest)
+		}
+	}
+}
+
+// TestParseCommandLineErrors tests rejecting invalid command lines
+func TestParseCommandLineErrors(t *testing.T) {
+	tests := [][]string{
+		// Unknown flags, and flags of other commands
+		{"Go", "--bogus"},
+		{"list", "--merge"},
+		// Missing or unexpected values
+		{"Go", "-o"},
+		{"list", "--strict=yes"},
+		// Wrong number of arguments
+		{"check-ignore"},
+		{"list", "extra"},
+		{"preset", "save", "backend"},
+	}
+
+	for _, args := range tests {
+		if _, err := ParseCommandLine(commandTable(), args); err == nil {
+			t.Errorf("Expected ParseCommandLine(%v) to fail", args)
+		}
+	}
+}
+
+// TestRepeatedFlags tests that repeated flags keep every value
+func TestRepeatedFlags(t *testing.T) {
+	inv, err := ParseCommandLine(commandTable(), []string{"preset", "save", "web", "Node", "--extra", "*.env", "--extra=dist/"})
+	if err != nil {
+		t.Fatalf("ParseCommandLine returned error: %v", err)
+	}
+	if extra := inv.Strings("extra"); strings.Join(extra, ",") != "*.env,dist/" {
+		t.Errorf("Expected both extra lines, got %v", extra)
+	}
+	if inv.String("extra", "") != "dist/" {
+		t.Errorf("Expected the last value to win for String")
+	}
+}
+
+// TestAskWithoutInput tests that questions fail instead of blocking when no
+// answer can be given
+func TestAskWithoutInput(t *testing.T) {
+	originalStdinIsTerminal := stdinIsTerminal
+	defer func() { stdinIsTerminal = originalStdinIsTerminal }()
+	stdinIsTerminal = func() bool { return true }
+
+	inv, err := ParseCommandLine(commandTable(), []string{"clean", "--no-input"})
+	if err != nil {
+		t.Fatalf("ParseCommandLine returned error: %v", err)
+	}
+	if _, err := inv.Ask("Continue? "); err == nil || !strings.Contains(err.Error(), "--no-input") {
+		t.Errorf("Expected an error naming --no-input, got %v", err)
+	}
+
+	stdinIsTerminal = func() bool { return false }
+	inv, err = ParseCommandLine(commandTable(), []string{"clean"})
+	if err != nil {
+		t.Fatalf("ParseCommandLine returned error: %v", err)
+	}
+	if _, err := inv.Ask("Continue? "); err == nil || !strings.Contains(err.Error(), "not a terminal") {
+		t.Errorf("Expected an error about stdin, got %v", err)
+	}
+
+	for _, flag := range []string{"--yes", "-y", "--force"} {
+		inv, err = ParseCommandLine(commandTable(), []string{"clean", flag})
+		if err != nil {
+			t.Fatalf("ParseCommandLine returned error: %v", err)
+		}
+		if !inv.AssumeYes() {
+			t.Errorf("Expected %s to answer yes", flag)
+		}
+	}
+}
+
+// TestIsTerminal tests that files, pipes and the null device are not taken
+// for terminals
+func TestIsTerminal(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+	}
+	defer null.Close()
+	if isTerminal(null) {
+		t.Errorf("Expected %s not to be a terminal", os.DevNull)
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create a pipe: %v", err)
+	}
+	defer reader.Close()
+	defer writer.Close()
+	if isTerminal(reader) {
+		t.Error("Expected a pipe not to be a terminal")
+	}
+
+	file, err := ioutil.TempFile("", "gitignore-terminal-test")
+	if err != nil {
+		t.Fatalf("Failed to create a file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if isTerminal(file) {
+		t.Error("Expected a file not to be a terminal")
+	}
+}
@@ -0,0 +1,755 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Flags shared by the commands that write a .gitignore file
+var (
+	outputFlag     = Flag{Name: "output", Short: "o", Value: "file", Usage: "Write the generated file to <file> instead of .gitignore"}
+	underFlag      = Flag{Name: "under", Value: "dir", Usage: "Rewrite the template rules so they only apply inside <dir>"}
+	mergeFlag      = Flag{Name: "merge", Usage: "Merge into an existing file instead of asking to overwrite it; lines already present are skipped. Files that already contain getignore blocks are always merged"}
+	noDefaultsFlag = Flag{Name: "no-defaults", Usage: "Do not add the default templates from the config file"}
+)
+
+// commandTable returns every command of the CLI, in the order they are
+// listed in the help
+func commandTable() []*Command {
+	return []*Command{
+		{
+			Name:        "generate",
+			Args:        "<template>... [file]",
+			Summary:     "Generate a .gitignore file for one or more templates. The command name may be left out unless a template is named like a command",
+			Description: "Templates may be separated by spaces or commas. A last argument containing a dot, such as output.txt, is taken as the output file.",
+			Flags: []Flag{
+				outputFlag,
+				underFlag,
+				mergeFlag,
+				noDefaultsFlag,
+				{Name: "download-all", Usage: "Download all templates instead of just the requested ones"},
+			},
+			MinArgs: 1,
+			MaxArgs: -1,
+			Run:     runGenerate,
+		},
+		{
+			Name:    "list",
+			Summary: "List all available templates",
+			Run:     runList,
+		},
+		{
+			Name:    "search",
+			Args:    "<term>",
+			Summary: "Search the templates by name, allowing for typos",
+			MinArgs: 1,
+			MaxArgs: -1,
+			Run:     runSearch,
+		},
+		{
+			Name:    "preset",
+			Args:    "<name>",
+			Summary: "Generate a .gitignore from a preset, or manage the presets",
+			Flags:   []Flag{outputFlag, underFlag, mergeFlag, noDefaultsFlag},
+			MinArgs: 1,
+			MaxArgs: 1,
+			Run:     runPreset,
+			Subcommands: []*Command{
+				{
+					Name:    "list",
+					Summary: "List all presets and where they are defined",
+					Run:     runPresetList,
+				},
+				{
+					Name:    "show",
+					Args:    "<name>",
+					Summary: "Show the templates a preset resolves to",
+					MinArgs: 1,
+					MaxArgs: 1,
+					Run:     runPresetShow,
+				},
+				{
+					Name:    "save",
+					Args:    "<name> <template>...",
+					Summary: "Add or replace a preset in the user config",
+					Flags: []Flag{
+						{Name: "extra", Value: "line", Usage: "Add a line to the preset's own block; may be repeated"},
+						{Name: "project", Usage: "Save the preset in " + projectConfigFileName + " in the current directory"},
+					},
+					MinArgs: 2,
+					MaxArgs: -1,
+					Run:     runPresetSave,
+				},
+			},
+		},
+		{
+			Name:    "detect",
+			Summary: "Suggest templates based on the files in the current directory",
+			Run:     runDetect,
+		},
+		{
+			Name:    "auto",
+			Summary: "Detect the project types and generate the .gitignore directly",
+			Flags:   []Flag{outputFlag, mergeFlag, noDefaultsFlag},
+			Run:     runDetect,
+		},
+		{
+			Name:    "monorepo",
+			Summary: "Detect subprojects and propose a .gitignore for each of them",
+			Flags: []Flag{
+				{Name: "write", Usage: "Create or update the proposed files"},
+			},
+			Run: runMonorepo,
+		},
+		{
+			Name:    "update",
+			Summary: "Update templates from the template source",
+			Run:     runUpdate,
+		},
+		{
+			Name:    "upgrade",
+			Args:    "[file]",
+			Summary: "Refresh the getignore blocks in a .gitignore file from the local templates",
+			Flags: []Flag{
+				{Name: "update", Usage: "Update the templates from the template source first"},
+			},
+			MaxArgs: 1,
+			Run:     runUpgrade,
+		},
+		{
+			Name:    "download-all",
+			Summary: "Download all templates from the template source",
+			Run:     runDownloadAll,
+		},
+		{
+			Name:    "check-ignore",
+			Args:    "<path>...",
+			Summary: "Check whether paths are ignored by .gitignore",
+			Flags: []Flag{
+				{Name: "verbose", Short: "v", Usage: "Show the rule that decided each path"},
+				{Name: "file", Short: "f", Value: "file", Usage: "Use another gitignore file"},
+			},
+			MinArgs: 1,
+			MaxArgs: -1,
+			Run:     runCheckIgnore,
+		},
+		{
+			Name:    "explain",
+			Args:    "<path>...",
+			Summary: "Show which rule, from which template, ignores a path and which earlier rules it overrides",
+			Flags: []Flag{
+				{Name: "file", Short: "f", Value: "file", Usage: "Use another gitignore file"},
+			},
+			MinArgs: 1,
+			MaxArgs: -1,
+			Run:     runExplain,
+		},
+		{
+			Name:    "clean",
+			Summary: "Remove all downloaded templates, keeping the settings",
+			Run:     runClean,
+		},
+		{
+			Name:    "help",
+			Args:    "[command]",
+			Summary: "Show this help message, or the help of a command",
+			MaxArgs: -1,
+			Run:     runHelp,
+		},
+	}
+}
+
+// loadConfig reads the user's config. It exits the program on errors.
+func loadConfig(inv *Invocation) Config {
+	config, err := LoadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(exitError)
+	}
+	if inv.Bool("no-defaults") {
+		config.Defaults = nil
+	}
+	return config
+}
+
+// newDownloader creates the downloader for the configured template source.
+// Flags take precedence over environment variables, which take precedence
+// over the config file. It exits the program on errors.
+func newDownloader(inv *Invocation, config Config) *Downloader {
+	upstream := defaultUpstream
+	if config.Upstream != nil {
+		upstream = upstream.Override(*config.Upstream)
+	}
+	upstream = upstream.Override(upstreamFromEnv()).Override(Upstream{
+		APIURL: inv.String("api-url", ""),
+		RawURL: inv.String("raw-url", ""),
+		Repo:   inv.String("repo", ""),
+		Branch: inv.String("branch", ""),
+	})
+
+	spec := config.Source
+	if env := os.Getenv("GETIGNORE_SOURCE"); env != "" {
+		spec = env
+	}
+	source, err := ParseSource(inv.String("source", spec), upstream, &http.Client{Timeout: 30 * time.Second})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	return &Downloader{Source: source, Aliases: config.AllAliases()}
+}
+
+// newRegistry creates the source of the configured team registry, or returns
+// nil if there is none. The flag takes precedence over the environment
+// variable, which takes precedence over the config file. It exits the
+// program on errors.
+func newRegistry(inv *Invocation, config Config) TemplateSource {
+	spec := config.Registry
+	if env := os.Getenv("GETIGNORE_REGISTRY"); env != "" {
+		spec = env
+	}
+	spec = inv.String("registry", spec)
+	if spec == "" {
+		return nil
+	}
+
+	registry, err := ParseRegistry(spec)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	return registry
+}
+
+// loadTemplates reads the config and the local templates. It exits the
+// program on errors.
+func loadTemplates(inv *Invocation) (*Templates, Config) {
+	config := loadConfig(inv)
+
+	templates := NewTemplates()
+	templates.Strict = inv.Bool("strict")
+	templates.Aliases = config.AllAliases()
+	templates.Downloader = newDownloader(inv, config)
+	templates.Registry = newRegistry(inv, config)
+	err := templates.LoadTemplates()
+	if err != nil {
+		fmt.Printf("Error loading templates: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	return templates, config
+}
+
+// runHelp prints the general help, or the help of the named command
+func runHelp(inv *Invocation) {
+	if len(inv.Args) == 0 {
+		printHelp(inv.commands)
+		return
+	}
+
+	commands := inv.commands
+	var command *Command
+	var path []string
+	for _, name := range inv.Args {
+		command = findCommand(commands, name)
+		if command == nil {
+			fmt.Printf("Error: unknown command '%s'\n", strings.Join(append(path, name), " "))
+			os.Exit(exitError)
+		}
+		path = append(path, command.Name)
+		commands = command.Subcommands
+	}
+	printCommandHelp(strings.Join(path, " "), command)
+}
+
+// runGenerate writes a .gitignore for the requested templates
+func runGenerate(inv *Invocation) {
+	args, err := parseGenerateArgs(inv)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	// If download-all flag is present, always download all templates
+	if inv.Bool("download-all") {
+		templatesDir, err := getTemplatesDir()
+		if err != nil {
+			fmt.Printf("Error getting templates directory: %v\n", err)
+			os.Exit(exitError)
+		}
+
+		downloader := newDownloader(inv, loadConfig(inv))
+		fmt.Printf("Downloading all templates from %s...\n", downloader.Source.Name())
+		err = downloader.DownloadTemplates(templatesDir)
+		if err != nil {
+			fmt.Printf("Error downloading templates: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+
+	templates, config := loadTemplates(inv)
+	names := withDefaults(templates, args.Names, config.Defaults)
+	generateGitignore(inv, templates, names, nil, args.OutputPath, args.Under)
+}
+
+// runList lists all available templates, grouped by directory
+func runList(inv *Invocation) {
+	allTemplates, _ := loadTemplates(inv)
+	templateList := allTemplates.ListTemplates()
+	fmt.Printf("Available templates (%d):\n", len(templateList))
+
+	// Group templates by directory
+	groups := make(map[string][]string)
+	for _, t := range templateList {
+		if strings.Contains(t, "/") {
+			parts := strings.SplitN(t, "/", 2)
+			groups[parts[0]] = append(groups[parts[0]], parts[1])
+		} else {
+			groups["Main"] = append(groups["Main"], t)
+		}
+	}
+
+	// Print templates by group, Main first
+	var groupNames []string
+	for group := range groups {
+		if group != "Main" {
+			groupNames = append(groupNames, group)
+		}
+	}
+	sort.Strings(groupNames)
+	if _, ok := groups["Main"]; ok {
+		groupNames = append([]string{"Main"}, groupNames...)
+	}
+	for _, group := range groupNames {
+		templates := groups[group]
+		fmt.Printf("\n%s:\n", group)
+		for _, t := range templates {
+			fmt.Printf("  - %s%s\n", t, layerNote(allTemplates, group, t))
+		}
+	}
+}
+
+// layerNote describes where a template listed in a group comes from, unless
+// it is an upstream template shadowing nothing
+func layerNote(templates *Templates, group, name string) string {
+	if group != "Main" {
+		name = group + "/" + name
+	}
+	layer, shadows := templates.Origin(name)
+	if layer == layerUpstream && len(shadows) == 0 {
+		return ""
+	}
+	if len(shadows) == 0 {
+		return fmt.Sprintf(" (%s)", layer)
+	}
+	return fmt.Sprintf(" (%s, shadows %s)", layer, strings.Join(shadows, ", "))
+}
+
+// runSearch prints the templates matching a search term
+func runSearch(inv *Invocation) {
+	templates, _ := loadTemplates(inv)
+	term := strings.Join(inv.Args, " ")
+	matches := templates.Search(term)
+	if len(matches) == 0 {
+		fmt.Printf("No templates match '%s'\n", term)
+		fmt.Println("Try 'gitignore download-all' to download all templates")
+		os.Exit(exitError)
+	}
+
+	fmt.Printf("Templates matching '%s' (%d):\n", term, len(matches))
+	for _, match := range matches {
+		fmt.Printf("  - %s\n", match)
+	}
+}
+
+// loadPresets reads the presets of the user config and the current
+// directory. It exits the program on errors.
+func loadPresets(config Config) *Presets {
+	presets, err := LoadPresets(config, ".")
+	if err != nil {
+		fmt.Printf("Error loading presets: %v\n", err)
+		os.Exit(exitError)
+	}
+	return presets
+}
+
+// runPreset generates a .gitignore from a preset
+func runPreset(inv *Invocation) {
+	templates, config := loadTemplates(inv)
+	presets := loadPresets(config)
+
+	name := inv.Args[0]
+	preset, ok := presets.Get(name)
+	if !ok {
+		fmt.Printf("Error: preset '%s' not found\n", name)
+		fmt.Println("Try 'gitignore preset list' to see available presets")
+		os.Exit(exitError)
+	}
+
+	under := inv.String("under", "")
+	var extras []TemplateSection
+	if section := preset.ExtraSection(under); section != nil {
+		extras = append(extras, *section)
+	}
+	names := withDefaults(templates, preset.Templates, config.Defaults)
+	generateGitignore(inv, templates, names, extras, inv.String("output", ".gitignore"), under)
+}
+
+// runPresetList lists all presets
+func runPresetList(inv *Invocation) {
+	config, err := LoadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	list := loadPresets(config).List()
+	if len(list) == 0 {
+		fmt.Println("No presets defined. Create one with 'gitignore preset save <name> <template>...'")
+		return
+	}
+	fmt.Printf("Available presets (%d):\n", len(list))
+	for _, preset := range list {
+		fmt.Printf("  %-20s %s (%s)\n", preset.Name, strings.Join(preset.Templates, " "), preset.Source)
+	}
+}
+
+// runPresetShow prints a preset with the templates it resolves to
+func runPresetShow(inv *Invocation) {
+	templates, config := loadTemplates(inv)
+	preset, ok := loadPresets(config).Get(inv.Args[0])
+	if !ok {
+		fmt.Printf("Error: preset '%s' not found\n", inv.Args[0])
+		os.Exit(exitError)
+	}
+
+	fmt.Printf("Preset '%s' (from %s):\n", preset.Name, preset.Source)
+	for _, name := range preset.Templates {
+		resolved, err := templates.ResolveTemplate(name)
+		switch {
+		case err == nil && resolved != name:
+			fmt.Printf("  %-30s -> %s\n", name, resolved)
+		case err == nil:
+			fmt.Printf("  %s\n", name)
+		default:
+			fmt.Printf("  %-30s (%v)\n", name, err)
+		}
+	}
+	if len(preset.Extra) > 0 {
+		fmt.Println("Extra lines:")
+		for _, line := range preset.Extra {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
+
+// runPresetSave adds or replaces a preset in the user or project config
+func runPresetSave(inv *Invocation) {
+	name := inv.Args[0]
+	preset := Preset{
+		Templates: splitTemplateNames(inv.Args[1:]),
+		Extra:     inv.Strings("extra"),
+	}
+
+	path := projectConfigFileName
+	if !inv.Bool("project") {
+		var err error
+		path, err = configPath()
+		if err != nil {
+			fmt.Printf("Error getting templates directory: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+
+	err := SavePreset(path, name, preset)
+	if err != nil {
+		fmt.Printf("Error saving preset: %v\n", err)
+		os.Exit(exitError)
+	}
+	fmt.Printf("Saved preset '%s' to '%s'\n", name, path)
+}
+
+// runDetect suggests templates based on the files in the current directory,
+// and for "auto" generates the .gitignore from them
+func runDetect(inv *Invocation) {
+	templates, config := loadTemplates(inv)
+
+	templatesDir, err := getTemplatesDir()
+	if err != nil {
+		fmt.Printf("Error getting templates directory: %v\n", err)
+		os.Exit(exitError)
+	}
+	detectionConfig, err := LoadDetectionConfig(templatesDir)
+	if err != nil {
+		fmt.Printf("Error loading detection rules: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	detections, err := DetectProjectTypes(".", templates, detectionConfig)
+	if err != nil {
+		fmt.Printf("Error scanning current directory: %v\n", err)
+		os.Exit(exitError)
+	}
+	if len(detections) == 0 {
+		fmt.Println("No known project types detected in the current directory")
+		os.Exit(exitError)
+	}
+
+	fmt.Println("Detected project types:")
+	var names []string
+	for _, detection := range detections {
+		note := ""
+		if !detection.Available {
+			note = " (not downloaded yet)"
+		}
+		fmt.Printf("  %-30s %s%s\n", detection.Template, strings.Join(detection.Evidence, ", "), note)
+		names = append(names, detection.Template)
+	}
+
+	fmt.Println()
+	if inv.Command.Name == "auto" {
+		outputPath := inv.String("output", ".gitignore")
+		generateGitignore(inv, templates, withDefaults(templates, names, config.Defaults), nil, outputPath, "")
+		return
+	}
+	fmt.Printf("Run 'gitignore auto' or 'gitignore %s' to generate the .gitignore\n", strings.Join(names, " "))
+}
+
+// runMonorepo proposes, or writes, a .gitignore for every subproject
+func runMonorepo(inv *Invocation) {
+	templates, _ := loadTemplates(inv)
+	writeFlag := inv.Bool("write")
+
+	templatesDir, err := getTemplatesDir()
+	if err != nil {
+		fmt.Printf("Error getting templates directory: %v\n", err)
+		os.Exit(exitError)
+	}
+	detectionConfig, err := LoadDetectionConfig(templatesDir)
+	if err != nil {
+		fmt.Printf("Error loading detection rules: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	rootGitignore, err := ioutil.ReadFile(".gitignore")
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error reading root .gitignore: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	subprojects, err := FindSubprojects(".", templates, detectionConfig, string(rootGitignore))
+	if err != nil {
+		fmt.Printf("Error scanning subprojects: %v\n", err)
+		os.Exit(exitError)
+	}
+	if len(subprojects) == 0 {
+		fmt.Println("No subprojects detected below the current directory")
+		return
+	}
+
+	for _, subproject := range subprojects {
+		outputPath := filepath.Join(filepath.FromSlash(subproject.Dir), ".gitignore")
+		fmt.Printf("%s:\n", outputPath)
+		for _, detection := range subproject.Detections {
+			fmt.Printf("  %-30s %s\n", detection.Template, strings.Join(detection.Evidence, ", "))
+		}
+		for _, covered := range subproject.Covered {
+			fmt.Printf("  %-30s already in the root .gitignore\n", covered)
+		}
+		if !writeFlag || len(subproject.Detections) == 0 {
+			continue
+		}
+
+		// Leave out rules the root .gitignore already applies here
+		var sections []TemplateSection
+		for _, detection := range subproject.Detections {
+			name, templateContent, err := fetchTemplate(templates, detection.Template)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitError)
+			}
+			sections = append(sections, TemplateSection{
+				Name:    name,
+				Content: withoutRootRules(string(rootGitignore), templateContent),
+			})
+		}
+
+		err = WriteGitignore(sections, outputPath, WriteMerge)
+		if err != nil {
+			fmt.Printf("Error writing '%s': %v\n", outputPath, err)
+			os.Exit(exitError)
+		}
+		fmt.Printf("  written\n")
+	}
+
+	if !writeFlag {
+		fmt.Println()
+		fmt.Println("Run 'gitignore monorepo --write' to write these files")
+	}
+}
+
+// runUpdate re-downloads the templates
+func runUpdate(inv *Invocation) {
+	updateTemplates(inv)
+	fmt.Println("Templates updated successfully!")
+}
+
+// updateTemplates replaces the local templates with those of the configured
+// source and reports the revision they come from. It exits the program on
+// errors.
+func updateTemplates(inv *Invocation) {
+	downloader := newDownloader(inv, loadConfig(inv))
+	fmt.Printf("Updating templates from %s...\n", downloader.Source.Name())
+	err := downloader.UpdateTemplates()
+	if err != nil {
+		fmt.Printf("Error updating templates: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	if revision, err := downloader.Source.Revision(); err == nil && revision != "" {
+		fmt.Printf("Templates are at revision %s\n", revision)
+	}
+}
+
+// runUpgrade refreshes the managed blocks of an existing gitignore file
+func runUpgrade(inv *Invocation) {
+	outputPath := ".gitignore"
+	if len(inv.Args) > 0 {
+		outputPath = inv.Args[0]
+	}
+
+	if inv.Bool("update") {
+		updateTemplates(inv)
+	}
+
+	templates, _ := loadTemplates(inv)
+	results, err := UpgradeGitignore(templates, outputPath)
+	if err != nil {
+		fmt.Printf("Error upgrading '%s': %v\n", outputPath, err)
+		os.Exit(exitError)
+	}
+	if len(results) == 0 {
+		fmt.Printf("No getignore blocks found in '%s'\n", outputPath)
+		return
+	}
+
+	fmt.Printf("Upgraded '%s':\n", outputPath)
+	for _, result := range results {
+		fmt.Printf("  %-30s %s\n", result.Name, result.Status)
+	}
+}
+
+// runDownloadAll downloads every template
+func runDownloadAll(inv *Invocation) {
+	templatesDir, err := getTemplatesDir()
+	if err != nil {
+		fmt.Printf("Error getting templates directory: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	downloader := newDownloader(inv, loadConfig(inv))
+	fmt.Printf("Downloading all templates from %s...\n", downloader.Source.Name())
+	err = downloader.DownloadTemplates(templatesDir)
+	if err != nil {
+		fmt.Printf("Error downloading templates: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	fmt.Println("All templates downloaded successfully!")
+}
+
+// runCheckIgnore prints the paths that are ignored, like git check-ignore.
+// It exits with 1 when no path is ignored and with 128 on errors.
+func runCheckIgnore(inv *Invocation) {
+	gitignorePath := inv.String("file", ".gitignore")
+	verbose := inv.Bool("verbose")
+
+	matcher, err := loadMatcher(gitignorePath)
+	if err != nil {
+		fmt.Printf("Error reading '%s': %v\n", gitignorePath, err)
+		os.Exit(exitFatal)
+	}
+
+	anyIgnored := false
+	for _, p := range inv.Args {
+		name, isDir, err := matchPath(gitignorePath, p)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitFatal)
+		}
+
+		result := matcher.Match(name, isDir)
+		if result.Ignored {
+			anyIgnored = true
+		}
+		if verbose && result.Matched {
+			fmt.Printf("%s:%d:%s\t%s\n", gitignorePath, result.Rule.Line, strings.TrimSpace(result.Rule.Raw), p)
+		} else if result.Ignored {
+			fmt.Println(p)
+		}
+	}
+
+	if !anyIgnored {
+		os.Exit(exitNotIgnored)
+	}
+}
+
+// runExplain shows which rules decide whether paths are ignored
+func runExplain(inv *Invocation) {
+	gitignorePath := inv.String("file", ".gitignore")
+	content, err := ioutil.ReadFile(gitignorePath)
+	if err != nil {
+		fmt.Printf("Error reading '%s': %v\n", gitignorePath, err)
+		os.Exit(exitError)
+	}
+
+	for i, p := range inv.Args {
+		name, isDir, err := matchPath(gitignorePath, p)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitError)
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		printExplanation(gitignorePath, p, ExplainPath(string(content), name, isDir))
+	}
+}
+
+// runClean removes all locally stored templates after asking for confirmation
+func runClean(inv *Invocation) {
+	templatesDir, err := getTemplatesDir()
+	if err != nil {
+		fmt.Printf("Error getting templates directory: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	if !inv.AssumeYes() {
+		response, err := inv.Ask("Are you sure you want to remove all downloaded templates? (y/n): ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println("Use --yes to remove the templates without asking")
+			os.Exit(exitError)
+		}
+		if response != "y" && response != "yes" {
+			fmt.Println("Operation cancelled")
+			os.Exit(exitCancelled)
+		}
+	}
+
+	err = removeTemplates(templatesDir)
+	if err != nil {
+		fmt.Printf("Error removing templates: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	fmt.Println("Templates successfully removed")
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configFileName is the name of the user's config file in the templates
+// directory
+const configFileName = "config.json"
+
+// Config holds the user's settings from the config file
+type Config struct {
+	// Aliases maps alternative names to template names, e.g. "golang": "Go".
+	// An alias mapped to "" removes a built-in alias.
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Presets are named bundles of templates and extra lines
+	Presets map[string]Preset `json:"presets,omitempty"`
+	// Defaults are templates added to every generated .gitignore, unless
+	// --no-defaults is given
+	Defaults []string `json:"defaults,omitempty"`
+	// Source selects where templates come from, e.g. "dir:/srv/templates";
+	// see ParseSource
+	Source string `json:"source,omitempty"`
+	// Registry is the team's template directory or git checkout, whose
+	// templates shadow the user's and the upstream ones; see ParseRegistry
+	Registry string `json:"registry,omitempty"`
+	// Upstream overrides the GitHub repository templates are downloaded from
+	Upstream *Upstream `json:"upstream,omitempty"`
+}
+
+// builtinAliases are the aliases available without any configuration
+var builtinAliases = map[string]string{
+	"golang":     "Go",
+	"js":         "Node",
+	"javascript": "Node",
+	"nodejs":     "Node",
+	"ts":         "Node",
+	"typescript": "Node",
+	"py":         "Python",
+	"rb":         "Ruby",
+	"rs":         "Rust",
+	"cpp":        "C++",
+	"csharp":     "VisualStudio",
+	"dotnet":     "VisualStudio",
+	"idea":       "Global/JetBrains",
+	"intellij":   "Global/JetBrains",
+	"mac":        "Global/macOS",
+	"osx":        "Global/macOS",
+	"win":        "Global/Windows",
+	"vscode":     "Global/VisualStudioCode",
+	"vim":        "Global/Vim",
+	"emacs":      "Global/Emacs",
+}
+
+// LoadConfig reads the config file from the templates directory. A missing
+// file gives an empty config.
+func LoadConfig() (Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return Config{}, err
+	}
+	return loadConfigFile(path)
+}
+
+// configPath returns the path of the user's config file
+func configPath() (string, error) {
+	templatesDir, err := getTemplatesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(templatesDir, configFileName), nil
+}
+
+// loadConfigFile reads a config file. A missing file gives an empty config.
+func loadConfigFile(path string) (Config, error) {
+	var config Config
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return config, fmt.Errorf("error parsing %s: %v", filepath.Base(path), err)
+	}
+
+	return config, nil
+}
+
+// saveConfigFile writes a config file as indented JSON
+func saveConfigFile(path string, config Config) error {
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// upstreamFromEnv reads upstream overrides from the environment
+func upstreamFromEnv() Upstream {
+	return Upstream{
+		APIURL: os.Getenv("GETIGNORE_API_URL"),
+		RawURL: os.Getenv("GETIGNORE_RAW_URL"),
+		Repo:   os.Getenv("GETIGNORE_REPO"),
+		Branch: os.Getenv("GETIGNORE_BRANCH"),
+	}
+}
+
+// AllAliases returns the built-in aliases combined with the configured ones,
+// keyed by lower case alias
+func (c Config) AllAliases() map[string]string {
+	aliases := make(map[string]string)
+	for alias, target := range builtinAliases {
+		aliases[alias] = target
+	}
+	for alias, target := range c.Aliases {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		if target == "" {
+			delete(aliases, alias)
+			continue
+		}
+		aliases[alias] = target
+	}
+	return aliases
+}
+
+// expandAlias returns the template name an alias stands for, or the name
+// itself if it is not an alias
+func expandAlias(name string, aliases map[string]string) string {
+	if target, ok := aliases[strings.ToLower(name)]; ok {
+		return target
+	}
+	return name
+}
+
+// withDefaults appends the default templates to the requested names, leaving
+// out defaults that resolve to a template that was already requested
+func withDefaults(templates *Templates, names, defaults []string) []string {
+	requested := make(map[string]bool)
+	for _, name := range names {
+		requested[templateKey(templates, name)] = true
+	}
+
+	result := append([]string(nil), names...)
+	for _, name := range defaults {
+		key := templateKey(templates, name)
+		if !requested[key] {
+			requested[key] = true
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// templateKey identifies the template a name stands for: its resolved name,
+// or the lower case name with aliases expanded when it is not available
+// locally
+func templateKey(templates *Templates, name string) string {
+	if resolved, err := templates.ResolveTemplate(name); err == nil {
+		return resolved
+	}
+	return strings.ToLower(expandAlias(name, templates.Aliases))
+}
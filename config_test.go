@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadConfig tests reading the config file from the templates directory
+func TestLoadConfig(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
+
+	// A missing file gives the built-in aliases
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if target := config.AllAliases()["golang"]; target != "Go" {
+		t.Errorf("Expected built-in alias golang=Go, got %q", target)
+	}
+
+	content := `{"aliases": {"Web": "Node", "golang": "community/Golang/Hugo", "mac": ""}}`
+	err = ioutil.WriteFile(filepath.Join(tempDir, ".gitignore-cli", configFileName), []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	aliases := config.AllAliases()
+	if aliases["web"] != "Node" {
+		t.Errorf("Expected alias web=Node, got %q", aliases["web"])
+	}
+	if aliases["golang"] != "community/Golang/Hugo" {
+		t.Errorf("Expected the configured alias to replace the built-in one, got %q", aliases["golang"])
+	}
+	if _, ok := aliases["mac"]; ok {
+		t.Errorf("Expected an empty target to remove the built-in alias")
+	}
+	if aliases["idea"] != "Global/JetBrains" {
+		t.Errorf("Expected other built-in aliases to be kept, got %q", aliases["idea"])
+	}
+
+	err = ioutil.WriteFile(filepath.Join(tempDir, ".gitignore-cli", configFileName), []byte("{"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected an error for an invalid config file")
+	}
+}
+
+// TestWithDefaults tests appending the default templates to a generate call
+func TestWithDefaults(t *testing.T) {
+	templates := NewTemplates()
+	templates.templates["Go"] = "Go"
+	templates.templates["Global/macOS"] = "macOS"
+	templates.templates["Global/Windows"] = "Windows"
+	templates.Aliases = Config{}.AllAliases()
+
+	tests := []struct {
+		names    []string
+		defaults []string
+		expected []string
+	}{
+		{[]string{"Go"}, nil, []string{"Go"}},
+		{[]string{"Go"}, []string{"Global/macOS", "Global/Windows"}, []string{"Go", "Global/macOS", "Global/Windows"}},
+		// Defaults that were requested already, under any name, are skipped
+		{[]string{"mac", "golang"}, []string{"Global/macOS", "Go", "Windows"}, []string{"mac", "golang", "Windows"}},
+		// Defaults not available locally are still added, once
+		{[]string{"Go"}, []string{"Global/VisualStudioCode", "vscode"}, []string{"Go", "Global/VisualStudioCode"}},
+	}
+
+	for _, test := range tests {
+		names := withDefaults(templates, test.names, test.defaults)
+		if strings.Join(names, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("withDefaults(%v, %v) = %v, expected %v", test.names, test.defaults, names, test.expected)
+		}
+	}
+}
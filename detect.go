@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// detectionRulesFileName is the name of the user's detection rules file in
+// the templates directory
+const detectionRulesFileName = "detect.json"
+
+// maxDetectionFileSize limits how much of a file is read for content rules
+const maxDetectionFileSize = 1 << 20
+
+// DetectionRule is one piece of evidence for a template: a glob matched
+// against the entries of the project directory, optionally combined with a
+// regular expression the content of the matching file must contain. A glob
+// ending in '/' only matches directories.
+type DetectionRule struct {
+	Template string `json:"template"`
+	Glob     string `json:"glob"`
+	Contains string `json:"contains,omitempty"`
+	// Weight is added to the template's score when the rule matches; it
+	// defaults to 1 and may be negative to count against a template
+	Weight int `json:"weight,omitempty"`
+}
+
+// DetectionConfig is the content of the detection rules file
+type DetectionConfig struct {
+	// ReplaceBuiltin drops all built-in rules instead of extending them
+	ReplaceBuiltin bool `json:"replace_builtin,omitempty"`
+	// Disable lists templates that should never be suggested
+	Disable []string `json:"disable,omitempty"`
+	// MinScore is the score a template needs to be suggested; it defaults to 1
+	MinScore int `json:"min_score,omitempty"`
+	// Rules are the user's rules. Rules for a template replace the built-in
+	// rules for that template; rules for other templates are added.
+	Rules []DetectionRule `json:"rules,omitempty"`
+}
+
+// builtinDetectionRules are the project types recognised out of the box
+var builtinDetectionRules = []DetectionRule{
+	{Template: "Go", Glob: "go.mod"},
+	{Template: "Node", Glob: "package.json"},
+	{Template: "Rust", Glob: "Cargo.toml"},
+	{Template: "Maven", Glob: "pom.xml"},
+	{Template: "Gradle", Glob: "build.gradle"},
+	{Template: "Gradle", Glob: "build.gradle.kts"},
+	{Template: "VisualStudio", Glob: "*.csproj"},
+	{Template: "VisualStudio", Glob: "*.sln"},
+	{Template: "Python", Glob: "pyproject.toml"},
+	{Template: "Python", Glob: "requirements.txt"},
+	{Template: "Python", Glob: "setup.py"},
+	{Template: "Ruby", Glob: "Gemfile"},
+	{Template: "Terraform", Glob: "*.tf"},
+	{Template: "Unity", Glob: "ProjectSettings/"},
+	{Template: "Global/JetBrains", Glob: ".idea/"},
+	{Template: "Global/VisualStudioCode", Glob: ".vscode/"},
+}
+
+// Detection is a template suggested for a project, with the files that
+// triggered the suggestion
+type Detection struct {
+	Template string
+	Evidence []string
+	Score    int
+	// Available reports whether the template is in the local templates
+	Available bool
+}
+
+// LoadDetectionConfig reads the detection rules file from the templates
+// directory. A missing file gives the built-in rules only.
+func LoadDetectionConfig(templatesDir string) (DetectionConfig, error) {
+	var config DetectionConfig
+	content, err := ioutil.ReadFile(filepath.Join(templatesDir, detectionRulesFileName))
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return config, fmt.Errorf("error parsing %s: %v", detectionRulesFileName, err)
+	}
+
+	for _, rule := range config.Rules {
+		if rule.Template == "" || rule.Glob == "" {
+			return config, fmt.Errorf("error in %s: every rule needs a template and a glob", detectionRulesFileName)
+		}
+		if _, err := regexp.Compile(rule.Contains); err != nil {
+			return config, fmt.Errorf("error in %s: invalid regex for %s: %v", detectionRulesFileName, rule.Template, err)
+		}
+	}
+
+	return config, nil
+}
+
+// EffectiveRules combines the built-in rules with the user's rules
+func (c DetectionConfig) EffectiveRules() []DetectionRule {
+	// Templates the user has written rules for, or disabled
+	overridden := make(map[string]bool)
+	for _, rule := range c.Rules {
+		overridden[strings.ToLower(rule.Template)] = true
+	}
+	disabled := make(map[string]bool)
+	for _, template := range c.Disable {
+		disabled[strings.ToLower(template)] = true
+	}
+
+	var rules []DetectionRule
+	if !c.ReplaceBuiltin {
+		for _, rule := range builtinDetectionRules {
+			if !overridden[strings.ToLower(rule.Template)] {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	rules = append(rules, c.Rules...)
+
+	// Drop disabled templates from both sets
+	var effective []DetectionRule
+	for _, rule := range rules {
+		if !disabled[strings.ToLower(rule.Template)] {
+			effective = append(effective, rule)
+		}
+	}
+	return effective
+}
+
+// DetectProjectTypes scans a directory for the evidence described by the
+// detection rules and suggests templates for it, in rule order. Template
+// names are taken from the local templates when they are available there.
+func DetectProjectTypes(dir string, templates *Templates, config DetectionConfig) ([]Detection, error) {
+	entries, err := readDirEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index the local template names case-insensitively
+	available := make(map[string]string)
+	for _, name := range templates.ListTemplates() {
+		available[strings.ToLower(name)] = name
+	}
+
+	minScore := config.MinScore
+	if minScore == 0 {
+		minScore = 1
+	}
+
+	// Score every template, keeping the order in which they first matched
+	var detections []*Detection
+	byTemplate := make(map[string]*Detection)
+	for _, rule := range config.EffectiveRules() {
+		evidence := matchDetectionRule(rule, dir, entries)
+		if len(evidence) == 0 {
+			continue
+		}
+
+		key := strings.ToLower(rule.Template)
+		detection, ok := byTemplate[key]
+		if !ok {
+			detection = &Detection{Template: rule.Template}
+			byTemplate[key] = detection
+			detections = append(detections, detection)
+		}
+
+		weight := rule.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		detection.Score += weight
+		detection.Evidence = append(detection.Evidence, evidence...)
+	}
+
+	var suggested []Detection
+	for _, detection := range detections {
+		if detection.Score < minScore {
+			continue
+		}
+		if name, ok := available[strings.ToLower(detection.Template)]; ok {
+			detection.Template = name
+			detection.Available = true
+		}
+		suggested = append(suggested, *detection)
+	}
+
+	return suggested, nil
+}
+
+// dirEntry is a directory entry name and whether it is a directory
+type dirEntry struct {
+	Name  string
+	IsDir bool
+}
+
+// readDirEntries lists a directory, sorted by name
+func readDirEntries(dir string) ([]dirEntry, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []dirEntry
+	for _, file := range files {
+		entries = append(entries, dirEntry{Name: file.Name(), IsDir: file.IsDir()})
+	}
+	return entries, nil
+}
+
+// matchDetectionRule returns the entries matching the rule, with a trailing
+// '/' for directories and the regex for content matches
+func matchDetectionRule(rule DetectionRule, dir string, entries []dirEntry) []string {
+	dirOnly := strings.HasSuffix(rule.Glob, "/")
+	glob := strings.TrimSuffix(rule.Glob, "/")
+
+	var contains *regexp.Regexp
+	if rule.Contains != "" {
+		contains = regexp.MustCompile(rule.Contains)
+	}
+
+	var evidence []string
+	for _, entry := range entries {
+		if entry.IsDir != dirOnly {
+			continue
+		}
+		if matched, _ := filepath.Match(glob, entry.Name); !matched {
+			continue
+		}
+
+		switch {
+		case entry.IsDir:
+			evidence = append(evidence, entry.Name+"/")
+		case contains != nil:
+			if fileContains(filepath.Join(dir, entry.Name), contains) {
+				evidence = append(evidence, fmt.Sprintf("%s contains /%s/", entry.Name, rule.Contains))
+			}
+		default:
+			evidence = append(evidence, entry.Name)
+		}
+	}
+	return evidence
+}
+
+// fileContains reports whether the start of the file matches the regex
+func fileContains(path string, pattern *regexp.Regexp) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(file, maxDetectionFileSize))
+	if err != nil {
+		return false
+	}
+	return pattern.Match(content)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDetectProjectTypes tests suggesting templates from marker files
+func TestDetectProjectTypes(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-detect-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"go.mod", "package.json", "App.csproj"} {
+		err = ioutil.WriteFile(filepath.Join(tempDir, name), []byte{}, 0644)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{".idea", "ProjectSettings"} {
+		err = os.Mkdir(filepath.Join(tempDir, name), 0755)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	templates := NewTemplates()
+	templates.templates["Go"] = "*.exe\n"
+	templates.templates["Global/JetBrains"] = ".idea/\n"
+	templates.templates["unity"] = "/Library/\n"
+
+	detections, err := DetectProjectTypes(tempDir, templates, DetectionConfig{})
+	if err != nil {
+		t.Fatalf("DetectProjectTypes returned error: %v", err)
+	}
+
+	var found []string
+	for _, detection := range detections {
+		found = append(found, fmt.Sprintf("%s=%s=%v", detection.Template, strings.Join(detection.Evidence, ","), detection.Available))
+	}
+
+	expected := []string{
+		"Go=go.mod=true",
+		"Node=package.json=false",
+		"VisualStudio=App.csproj=false",
+		"unity=ProjectSettings/=true",
+		"Global/JetBrains=.idea/=true",
+	}
+	if strings.Join(found, " ") != strings.Join(expected, " ") {
+		t.Errorf("Unexpected detections.\nExpected: %v\nGot:      %v", expected, found)
+	}
+}
+
+// TestDetectionConfig tests user rules overriding and extending the built-in rules
+func TestDetectionConfig(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-detect-config-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	rules := `{
+		"disable": ["Python"],
+		"min_score": 2,
+		"rules": [
+			{"template": "Node", "glob": "package.json"},
+			{"template": "Node", "glob": "package.json", "contains": "\"react\"", "weight": 2},
+			{"template": "Go", "glob": "go.mod", "weight": 2},
+			{"template": "Acme", "glob": "*.acme", "weight": 3}
+		]
+	}`
+	err = ioutil.WriteFile(filepath.Join(tempDir, detectionRulesFileName), []byte(rules), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write rules file: %v", err)
+	}
+
+	files := map[string]string{
+		"package.json":     `{"dependencies": {"react": "18"}}`,
+		"go.mod":           "module example",
+		"requirements.txt": "requests",
+		"Cargo.toml":       "[package]",
+		"build.acme":       "",
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	config, err := LoadDetectionConfig(tempDir)
+	if err != nil {
+		t.Fatalf("LoadDetectionConfig returned error: %v", err)
+	}
+
+	detections, err := DetectProjectTypes(tempDir, NewTemplates(), config)
+	if err != nil {
+		t.Fatalf("DetectProjectTypes returned error: %v", err)
+	}
+
+	// Rust only scores 1 with the built-in rule, Python is disabled
+	var found []string
+	for _, detection := range detections {
+		found = append(found, fmt.Sprintf("%s=%d", detection.Template, detection.Score))
+	}
+	expected := []string{"Node=3", "Go=2", "Acme=3"}
+	if strings.Join(found, " ") != strings.Join(expected, " ") {
+		t.Errorf("Unexpected detections.\nExpected: %v\nGot:      %v", expected, found)
+	}
+
+	// A broken regex is reported when loading the file
+	err = ioutil.WriteFile(filepath.Join(tempDir, detectionRulesFileName), []byte(`{"rules": [{"template": "X", "glob": "x", "contains": "("}]}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write rules file: %v", err)
+	}
+	if _, err := LoadDetectionConfig(tempDir); err == nil {
+		t.Error("Expected an error for an invalid regex")
+	}
+}
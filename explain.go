@@ -0,0 +1,51 @@
+package main
+
+// Explanation describes which rules of a gitignore file decide whether a
+// path is ignored, and which templates those rules came from
+type Explanation struct {
+	// Result is the outcome of matching the path
+	Result MatchResult
+	// Template is the managed block holding the deciding rule, or "" if the
+	// rule is outside any block
+	Template string
+	// Overridden lists the earlier rules that also match the path but lost
+	// to the deciding rule
+	Overridden []ExplainedRule
+}
+
+// ExplainedRule is a rule together with the template block it came from
+type ExplainedRule struct {
+	Rule     Rule
+	Template string
+}
+
+// ExplainPath matches a path against gitignore content and records the
+// deciding rule, its template and the rules it overrode
+func ExplainPath(content, name string, isDir bool) Explanation {
+	matcher := NewMatcher(ParseRules(content))
+	lineNames := blockLineNames(content)
+	templateOf := func(rule Rule) string {
+		if rule.Line-1 < len(lineNames) {
+			return lineNames[rule.Line-1]
+		}
+		return ""
+	}
+
+	explanation := Explanation{Result: matcher.Match(name, isDir)}
+	if !explanation.Result.Matched {
+		return explanation
+	}
+	explanation.Template = templateOf(explanation.Result.Rule)
+
+	// Every other rule matching the path itself lost. When a parent
+	// directory is excluded, even later rules lose because git never looks
+	// inside that directory.
+	for _, rule := range matcher.Matches(name, isDir) {
+		if rule.Line == explanation.Result.Rule.Line {
+			continue
+		}
+		explanation.Overridden = append(explanation.Overridden, ExplainedRule{Rule: rule, Template: templateOf(rule)})
+	}
+
+	return explanation
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+// TestExplainPath tests finding the deciding rule and its template
+func TestExplainPath(t *testing.T) {
+	content := "!debug.log\n\n" +
+		"# >>> getignore: Go\n*.exe\n*.log\n# <<< getignore: Go\n\n" +
+		"# >>> getignore: Node\nnode_modules/\n# <<< getignore: Node\n" +
+		"!keep.exe\n"
+
+	explanation := ExplainPath(content, "debug.log", false)
+	if !explanation.Result.Ignored || explanation.Result.Rule.Line != 5 || explanation.Template != "Go" {
+		t.Errorf("Unexpected explanation for debug.log: %+v", explanation)
+	}
+	if len(explanation.Overridden) != 1 || explanation.Overridden[0].Rule.Line != 1 || explanation.Overridden[0].Template != "" {
+		t.Errorf("Expected the user rule on line 1 to be overridden, got %+v", explanation.Overridden)
+	}
+
+	explanation = ExplainPath(content, "keep.exe", false)
+	if explanation.Result.Ignored || explanation.Result.Rule.Line != 11 || explanation.Template != "" {
+		t.Errorf("Unexpected explanation for keep.exe: %+v", explanation)
+	}
+
+	explanation = ExplainPath(content, "node_modules/pkg/keep.exe", false)
+	if !explanation.Result.Ignored || explanation.Result.Parent != "node_modules" || explanation.Template != "Node" {
+		t.Errorf("Unexpected explanation for node_modules/pkg/keep.exe: %+v", explanation)
+	}
+	if len(explanation.Overridden) != 2 {
+		t.Errorf("Expected 2 overridden rules, got %+v", explanation.Overridden)
+	}
+
+	explanation = ExplainPath(content, "main.go", false)
+	if explanation.Result.Matched {
+		t.Errorf("Expected no match for main.go, got %+v", explanation)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// gitTree is a recursive listing from the git trees API
+type gitTree struct {
+	SHA  string `json:"sha"`
+	Tree []struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+		SHA  string `json:"sha"`
+	} `json:"tree"`
+	Truncated bool `json:"truncated"`
+}
+
+// Upstream is the repository templates are downloaded from
+type Upstream struct {
+	// APIURL is the base URL of the GitHub API, e.g. https://api.github.com
+	APIURL string `json:"api_url,omitempty"`
+	// RawURL is the base URL raw file contents are served from
+	RawURL string `json:"raw_url,omitempty"`
+	// Repo is the repository as "owner/name"
+	Repo   string `json:"repo,omitempty"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// defaultUpstream is the official github/gitignore repository
+var defaultUpstream = Upstream{
+	APIURL: "https://api.github.com",
+	RawURL: "https://raw.githubusercontent.com",
+	Repo:   "github/gitignore",
+	Branch: "main",
+}
+
+// Override returns the upstream with the fields set in other replacing its own
+func (u Upstream) Override(other Upstream) Upstream {
+	if other.APIURL != "" {
+		u.APIURL = other.APIURL
+	}
+	if other.RawURL != "" {
+		u.RawURL = other.RawURL
+	}
+	if other.Repo != "" {
+		u.Repo = other.Repo
+	}
+	if other.Branch != "" {
+		u.Branch = other.Branch
+	}
+	return u
+}
+
+// apiURL returns the URL of a GitHub API endpoint of the repository
+func (u Upstream) apiURL(endpoint string) string {
+	return strings.TrimSuffix(u.APIURL, "/") + "/repos/" + u.Repo + "/" + endpoint
+}
+
+// rawURL returns the URL of a file's raw content in the repository
+func (u Upstream) rawURL(path string) string {
+	return strings.TrimSuffix(u.RawURL, "/") + "/" + u.Repo + "/" + u.Branch + "/" + path
+}
+
+// GitHubSource reads templates from a GitHub repository through the git
+// trees API and raw file URLs
+type GitHubSource struct {
+	Upstream Upstream
+	Client   *http.Client
+
+	// blobs maps the path of every template to its blob SHA once the tree
+	// has been listed
+	blobs map[string]string
+}
+
+// NewGitHubSource creates a source for the given repository
+func NewGitHubSource(upstream Upstream, client *http.Client) *GitHubSource {
+	return &GitHubSource{Upstream: upstream, Client: client}
+}
+
+// Name returns the repository and branch
+func (s *GitHubSource) Name() string {
+	return fmt.Sprintf("GitHub (%s, %s)", s.Upstream.Repo, s.Upstream.Branch)
+}
+
+// List returns the templates in the root, Global and community directories
+// of the repository, in lexical order
+func (s *GitHubSource) List() ([]string, error) {
+	err := s.loadTree()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for p := range s.blobs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Hashes returns the blob SHA of every template
+func (s *GitHubSource) Hashes() (map[string]string, error) {
+	err := s.loadTree()
+	if err != nil {
+		return nil, err
+	}
+	return s.blobs, nil
+}
+
+// loadTree lists the whole repository in one request, unless that has
+// happened already
+func (s *GitHubSource) loadTree() error {
+	if s.blobs != nil {
+		return nil
+	}
+
+	resp, err := s.Client.Get(s.Upstream.apiURL("git/trees/" + s.Upstream.Branch + "?recursive=1"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to list templates, status code: %d", resp.StatusCode)
+	}
+
+	var tree gitTree
+	err = json.NewDecoder(resp.Body).Decode(&tree)
+	if err != nil {
+		return err
+	}
+	if tree.Truncated {
+		return fmt.Errorf("the repository is too large to be listed in one request")
+	}
+
+	s.blobs = make(map[string]string)
+	for _, entry := range tree.Tree {
+		if entry.Type == "blob" && isUpstreamTemplatePath(entry.Path) {
+			s.blobs[entry.Path] = entry.SHA
+		}
+	}
+	return nil
+}
+
+// Fetch downloads the raw content of a file of the repository
+func (s *GitHubSource) Fetch(path string) ([]byte, error) {
+	resp, err := s.Client.Get(s.Upstream.rawURL(path))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errTemplateNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file, status code: %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Archive returns the templates of the branch as a single tarball download,
+// which needs one request instead of one per directory and file. Only
+// templates in the root, Global and community directories are taken.
+func (s *GitHubSource) Archive() TemplateSource {
+	return &TarballSource{
+		URL:    s.Upstream.apiURL("tarball/" + s.Upstream.Branch),
+		Client: s.Client,
+		Accept: isUpstreamTemplatePath,
+	}
+}
+
+// isUpstreamTemplatePath reports whether a path is where the github/gitignore
+// repository keeps templates
+func isUpstreamTemplatePath(p string) bool {
+	dir, _ := path.Split(p)
+	inTemplateDir := dir == "" || dir == "Global/" || strings.HasPrefix(dir, "community/")
+	return inTemplateDir && isTemplatePath(p)
+}
+
+// Revision returns the SHA of the commit the branch points to
+func (s *GitHubSource) Revision() (string, error) {
+	resp, err := s.Client.Get(s.Upstream.apiURL("commits/" + s.Upstream.Branch))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get the revision, status code: %d", resp.StatusCode)
+	}
+
+	var commit struct {
+		SHA string `json:"sha"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&commit)
+	if err != nil {
+		return "", err
+	}
+	return commit.SHA, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// overlayDirName is the directory holding your own templates, both in the
+// current directory and in the home directory
+const overlayDirName = ".gitignore-templates"
+
+// Names of the template layers
+const (
+	layerProject  = "project"
+	layerTeam     = "team"
+	layerUser     = "user"
+	layerUpstream = "upstream"
+)
+
+// Layer is a source of templates with a name. When several layers have a
+// template with the same name, the one listed first wins.
+type Layer struct {
+	Name   string
+	Source TemplateSource
+}
+
+// LoadLayers loads the templates of every layer. Layers are given highest
+// priority first; their templates shadow those of later layers with the
+// same name.
+func (t *Templates) LoadLayers(layers []Layer) error {
+	for i := len(layers) - 1; i >= 0; i-- {
+		err := t.loadLayer(layers[i])
+		if err != nil {
+			return fmt.Errorf("error loading %s templates from %s: %v", layers[i].Name, layers[i].Source.Name(), err)
+		}
+	}
+	return nil
+}
+
+// loadLayer loads every template of a layer's source. Templates that cannot
+// be read are skipped.
+func (t *Templates) loadLayer(layer Layer) error {
+	paths, err := layer.Source.List()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		content, err := layer.Source.Fetch(path)
+		if err != nil {
+			continue
+		}
+		t.addTemplate(strings.TrimSuffix(path, ".gitignore"), string(content), layer.Name)
+	}
+
+	return nil
+}
+
+// Origin returns the layer a template comes from and the lower layers whose
+// template of the same name it shadows
+func (t *Templates) Origin(name string) (string, []string) {
+	return t.origins[name], t.shadows[name]
+}
+
+// ParseRegistry creates the source of the team registry from spec: a
+// directory, optionally written as "dir:PATH", or a git checkout as
+// "git:PATH[@REF]". Registries are read on every run, so remote sources are
+// not allowed.
+func ParseRegistry(spec string) (TemplateSource, error) {
+	switch {
+	case strings.HasPrefix(spec, "dir:") || strings.HasPrefix(spec, "git:"):
+		return ParseSource(spec, defaultUpstream, nil)
+	case spec == "github" || strings.HasPrefix(spec, "tarball:"):
+		return nil, fmt.Errorf("the registry must be a local directory or git checkout, not '%s'", spec)
+	}
+	return &DirSource{Dir: spec}, nil
+}
+
+// templateLayers returns the layers templates are loaded from, highest
+// priority first: the project's own templates, the team registry if one is
+// given, the user's own templates and the local copy of the upstream
+// templates. Missing project and user directories are left out.
+func templateLayers(projectDir string, registry TemplateSource) ([]Layer, error) {
+	var layers []Layer
+	addDir := func(name, dir string) {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			layers = append(layers, Layer{Name: name, Source: &DirSource{Dir: dir}})
+		}
+	}
+
+	addDir(layerProject, filepath.Join(projectDir, overlayDirName))
+	if registry != nil {
+		layers = append(layers, Layer{Name: layerTeam, Source: registry})
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	addDir(layerUser, filepath.Join(homeDir, overlayDirName))
+
+	templatesDir, err := getTemplatesDir()
+	if err != nil {
+		return nil, fmt.Errorf("error getting templates directory: %v", err)
+	}
+	layers = append(layers, Layer{Name: layerUpstream, Source: &DirSource{Dir: templatesDir}})
+
+	return layers, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestLoadLayers tests that templates of higher layers shadow those of lower
+// layers with the same name
+func TestLoadLayers(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-layers-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeFiles(t, filepath.Join(tempDir, "project"), map[string]string{
+		"Go.gitignore": "# project Go\n",
+	})
+	writeFiles(t, filepath.Join(tempDir, "team"), map[string]string{
+		"Go.gitignore":      "# team Go\n",
+		"python.gitignore":  "# team Python\n",
+		"Company.gitignore": "# company\n",
+	})
+	writeFiles(t, filepath.Join(tempDir, "upstream"), map[string]string{
+		"Go.gitignore":           "# upstream Go\n",
+		"Python.gitignore":       "# upstream Python\n",
+		"Global/macOS.gitignore": "# upstream macOS\n",
+	})
+
+	templates := NewTemplates()
+	err = templates.LoadLayers([]Layer{
+		{Name: layerProject, Source: &DirSource{Dir: filepath.Join(tempDir, "project")}},
+		{Name: layerTeam, Source: &DirSource{Dir: filepath.Join(tempDir, "team")}},
+		{Name: layerUpstream, Source: &DirSource{Dir: filepath.Join(tempDir, "upstream")}},
+	})
+	if err != nil {
+		t.Fatalf("LoadLayers returned error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		layer   string
+		shadows []string
+	}{
+		{"Go", "# project Go\n", layerProject, []string{layerTeam, layerUpstream}},
+		// Names differing only in case are the same template
+		{"python", "# team Python\n", layerTeam, []string{layerUpstream}},
+		{"Company", "# company\n", layerTeam, nil},
+		{"Global/macOS", "# upstream macOS\n", layerUpstream, nil},
+	}
+
+	for _, test := range tests {
+		content, found := templates.GetTemplate(test.name)
+		if !found || content != test.content {
+			t.Errorf("GetTemplate(%q) = %q, %v; expected %q", test.name, content, found, test.content)
+		}
+		layer, shadows := templates.Origin(test.name)
+		if layer != test.layer || !reflect.DeepEqual(shadows, test.shadows) {
+			t.Errorf("Origin(%q) = %q, %v; expected %q, %v", test.name, layer, shadows, test.layer, test.shadows)
+		}
+	}
+
+	if content, _ := templates.GetTemplate("Python"); content != "# team Python\n" {
+		t.Errorf("Expected the shadowed upstream Python to be replaced, got %q", content)
+	}
+	if len(templates.ListTemplates()) != 4 {
+		t.Errorf("Expected 4 templates, got %v", templates.ListTemplates())
+	}
+}
+
+// TestTemplateLayers tests which layers are used and their order
+func TestTemplateLayers(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-layers-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
+
+	projectDir := filepath.Join(tempDir, "project")
+	err = os.MkdirAll(filepath.Join(projectDir, overlayDirName), 0755)
+	if err != nil {
+		t.Fatalf("Failed to create project templates: %v", err)
+	}
+
+	layerNames := func(layers []Layer) []string {
+		var names []string
+		for _, layer := range layers {
+			names = append(names, layer.Name)
+		}
+		return names
+	}
+
+	// The user has no templates of their own yet
+	layers, err := templateLayers(projectDir, &DirSource{Dir: "/srv/templates"})
+	if err != nil {
+		t.Fatalf("templateLayers returned error: %v", err)
+	}
+	if names := layerNames(layers); !reflect.DeepEqual(names, []string{layerProject, layerTeam, layerUpstream}) {
+		t.Errorf("Unexpected layers %v", names)
+	}
+
+	err = os.MkdirAll(filepath.Join(tempDir, overlayDirName), 0755)
+	if err != nil {
+		t.Fatalf("Failed to create user templates: %v", err)
+	}
+	layers, err = templateLayers(tempDir, nil)
+	if err != nil {
+		t.Fatalf("templateLayers returned error: %v", err)
+	}
+	// The home directory is also the project here
+	if names := layerNames(layers); !reflect.DeepEqual(names, []string{layerProject, layerUser, layerUpstream}) {
+		t.Errorf("Unexpected layers %v", names)
+	}
+}
+
+// TestParseRegistry tests the accepted registry sources
+func TestParseRegistry(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected TemplateSource
+	}{
+		{"/srv/templates", &DirSource{Dir: "/srv/templates"}},
+		{"dir:/srv/templates", &DirSource{Dir: "/srv/templates"}},
+		{"git:/srv/gitignore@stable", &GitSource{Dir: "/srv/gitignore", Ref: "stable"}},
+	}
+	for _, test := range tests {
+		registry, err := ParseRegistry(test.spec)
+		if err != nil {
+			t.Errorf("ParseRegistry(%q) returned error: %v", test.spec, err)
+		} else if !reflect.DeepEqual(registry, test.expected) {
+			t.Errorf("ParseRegistry(%q) = %#v, expected %#v", test.spec, registry, test.expected)
+		}
+	}
+
+	for _, spec := range []string{"github", "tarball:https://example.com/t.tar.gz"} {
+		if _, err := ParseRegistry(spec); err == nil {
+			t.Errorf("Expected an error for registry %q", spec)
+		}
+	}
+}
@@ -1,35 +1,48 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
-	"time"
 )
 
 // Templates struct to hold all the gitignore templates
 type Templates struct {
 	templates map[string]string
+	// Strict makes ResolveTemplate fail on any ambiguity instead of
+	// picking a template by precedence
+	Strict bool
+	// Aliases maps lower case alternative names to template names
+	Aliases map[string]string
+	// Downloader fetches templates that are not available locally
+	Downloader *Downloader
+	// Registry is the team's template source, whose templates shadow the
+	// user's and the upstream ones
+	Registry TemplateSource
+	// origins and shadows record the layer each template comes from and
+	// the lower layers that also have it
+	origins map[string]string
+	shadows map[string][]string
 }
 
-// TemplateFile represents a file from GitHub API
-type TemplateFile struct {
-	Name        string `json:"name"`
-	Path        string `json:"path"`
-	DownloadURL string `json:"download_url"`
-	Type        string `json:"type"`
+// Downloader copies templates from a template source into the local
+// templates directory
+type Downloader struct {
+	Source TemplateSource
+	// Aliases are expanded before a template is looked up in the source
+	Aliases map[string]string
 }
 
 // NewTemplates creates a new Templates instance
 func NewTemplates() *Templates {
 	return &Templates{
 		templates: make(map[string]string),
+		origins:   make(map[string]string),
+		shadows:   make(map[string][]string),
 	}
 }
 
@@ -53,130 +66,85 @@ func getTemplatesDir() (string, error) {
 	return templatesDir, nil
 }
 
-// LoadTemplates loads gitignore templates from local storage
+// LoadTemplates loads gitignore templates from local storage: the
+// templates of the current project, the team registry, the user's own
+// templates and the downloaded ones, in that order of priority
 func (t *Templates) LoadTemplates() error {
-	// Get templates directory
-	templatesDir, err := getTemplatesDir()
+	layers, err := templateLayers(".", t.Registry)
 	if err != nil {
-		return fmt.Errorf("error getting templates directory: %v", err)
+		return err
 	}
 
-	// Create templates directory if it doesn't exist
-	if _, err := os.Stat(templatesDir); os.IsNotExist(err) {
-		err = os.Mkdir(templatesDir, 0755)
-		if err != nil {
-			return fmt.Errorf("error creating templates directory: %v", err)
-		}
-	}
+	return t.LoadLayers(layers)
+}
+
+// LoadFrom loads every template of a source. Templates that cannot be read
+// are skipped.
+func (t *Templates) LoadFrom(source TemplateSource) error {
+	return t.loadLayer(Layer{Source: source})
+}
 
-	// Load templates from files
-	err = loadTemplatesFromDir(t, templatesDir, "")
+// DownloadSingleTemplate downloads a specific template from the template
+// source and saves it in the templates directory. It returns the template's
+// name, its path without the extension, which is what the name resolves to
+// once the template is cached.
+func (d *Downloader) DownloadSingleTemplate(framework string) (string, string, error) {
+	templatesDir, err := getTemplatesDir()
 	if err != nil {
-		return fmt.Errorf("error loading templates from directory: %v", err)
+		return "", "", fmt.Errorf("error getting templates directory: %v", err)
 	}
 
-	return nil
-}
+	// Look up aliases such as "golang" before going to the network
+	framework = expandAlias(framework, d.Aliases)
 
-// loadTemplatesFromDir loads templates from a directory and its subdirectories
-func loadTemplatesFromDir(t *Templates, dir, prefix string) error {
-	files, err := ioutil.ReadDir(dir)
+	// Resolve the name against the listing as it would be against the
+	// cache, ignoring case and ranking the directories
+	paths, err := d.Source.List()
 	if err != nil {
-		return fmt.Errorf("error reading directory %s: %v", dir, err)
+		return "", "", fmt.Errorf("error listing templates: %v", err)
 	}
-
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".gitignore") {
-			templatePath := filepath.Join(dir, file.Name())
-			templateName := strings.TrimSuffix(file.Name(), ".gitignore")
-			if prefix != "" {
-				templateName = prefix + "/" + templateName
-			}
-			t.loadTemplate(templatePath, templateName)
-		} else if file.IsDir() {
-			// Handle subdirectories
-			subDir := filepath.Join(dir, file.Name())
-			subPrefix := file.Name()
-			if prefix != "" {
-				subPrefix = prefix + "/" + subPrefix
-			}
-			loadTemplatesFromDir(t, subDir, subPrefix)
-		}
+	listed := NewTemplates()
+	for _, p := range paths {
+		listed.templates[strings.TrimSuffix(p, ".gitignore")] = ""
 	}
-
-	return nil
-}
-
-// DownloadSingleTemplate downloads a specific template from GitHub
-func DownloadSingleTemplate(framework string) (string, error) {
-	templatesDir, err := getTemplatesDir()
+	name, err := listed.resolveName(framework)
 	if err != nil {
-		return "", fmt.Errorf("error getting templates directory: %v", err)
+		return "", "", err
 	}
 
-	// Try different locations where the template might be
-	possiblePaths := []string{
-		framework + ".gitignore",                // Root directory
-		"Global/" + framework + ".gitignore",    // Global directory
-		"community/" + framework + ".gitignore", // Community directory
+	content, err := d.Source.Fetch(name + ".gitignore")
+	if err != nil {
+		return "", "", fmt.Errorf("error reading template content: %v", err)
 	}
 
-	// Try to find subdirectories in community
-	communityURL := "https://api.github.com/repos/github/gitignore/contents/community"
-	resp, err := http.Get(communityURL)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		defer resp.Body.Close()
-
-		var files []TemplateFile
-		err = json.NewDecoder(resp.Body).Decode(&files)
-		if err == nil {
-			for _, file := range files {
-				if file.Type == "dir" {
-					possiblePaths = append(possiblePaths,
-						"community/"+file.Name+"/"+framework+".gitignore")
-				}
-			}
-		}
+	// Save the template locally for future use
+	err = saveTemplate(templatesDir, name+".gitignore", content)
+	if err != nil {
+		return "", "", err
 	}
 
-	// Try each possible location
-	for _, path := range possiblePaths {
-		downloadURL := "https://raw.githubusercontent.com/github/gitignore/main/" + path
-		resp, err := http.Get(downloadURL)
-		if err != nil {
-			continue
-		}
-
-		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK {
-			continue
-		}
-
-		// We found the template!
-		content, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("error reading template content: %v", err)
-		}
-
-		// Save the template locally for future use
-		dirPath := filepath.Dir(filepath.Join(templatesDir, path))
-		if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-			err = os.MkdirAll(dirPath, 0755)
-			if err != nil {
-				return "", fmt.Errorf("error creating directory: %v", err)
-			}
-		}
+	return name, string(content), nil
+}
 
-		templatePath := filepath.Join(templatesDir, path)
-		err = ioutil.WriteFile(templatePath, content, 0644)
-		if err != nil {
-			return "", fmt.Errorf("error saving template: %v", err)
-		}
+// saveTemplate writes a template from a source to its path in the templates
+// directory
+func saveTemplate(templatesDir, path string, content []byte) error {
+	clean, err := cleanSourcePath(path)
+	if err != nil {
+		return err
+	}
 
-		return string(content), nil
+	templatePath := filepath.Join(templatesDir, filepath.FromSlash(clean))
+	err = os.MkdirAll(filepath.Dir(templatePath), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
 	}
 
-	return "", fmt.Errorf("template not found")
+	err = ioutil.WriteFile(templatePath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("error saving template: %v", err)
+	}
+	return nil
 }
 
 // loadTemplate reads a template file and adds it to the templates map
@@ -185,9 +153,29 @@ func (t *Templates) loadTemplate(path, name string) {
 	if err != nil {
 		return
 	}
+	t.addTemplate(name, string(content), "")
+}
+
+// addTemplate adds a template of a layer to the templates map, following a
+// reference to another template. A template with the same name, ignoring
+// case, from another layer is replaced and recorded as shadowed.
+func (t *Templates) addTemplate(name, content, layer string) {
+	if layer != "" {
+		for existing, origin := range t.origins {
+			if origin != layer && strings.EqualFold(existing, name) {
+				t.shadows[name] = append([]string{origin}, t.shadows[existing]...)
+				delete(t.templates, existing)
+				delete(t.origins, existing)
+				if existing != name {
+					delete(t.shadows, existing)
+				}
+			}
+		}
+		t.origins[name] = layer
+	}
 
 	// Handle template references (e.g., C++.gitignore inside Fortran.gitignore)
-	trimmedContent := strings.TrimSpace(string(content))
+	trimmedContent := strings.TrimSpace(content)
 	if !strings.Contains(trimmedContent, "\n") && strings.HasSuffix(trimmedContent, ".gitignore") {
 		referencedTemplate := strings.TrimSuffix(trimmedContent, ".gitignore")
 		if referenced, ok := t.templates[referencedTemplate]; ok {
@@ -196,406 +184,580 @@ func (t *Templates) loadTemplate(path, name string) {
 		}
 	}
 
-	t.templates[name] = string(content)
+	t.templates[name] = content
 }
 
-// downloadTemplates downloads templates from GitHub
-func downloadTemplates(templatesDir string) error {
-	baseURL := "https://api.github.com/repos/github/gitignore/contents"
-
-	// Download root templates
-	err := downloadTemplatesFromPath(baseURL, "", templatesDir)
-	if err != nil {
-		return err
-	}
+// DownloadTemplates downloads all templates from the template source into
+// the templates directory. Templates that are already up to date are
+// skipped when the source knows their blob SHAs; otherwise everything is
+// downloaded, as a single archive if the source offers one.
+func (d *Downloader) DownloadTemplates(templatesDir string) error {
+	return d.downloadTemplates(templatesDir, false)
+}
 
-	// Download Global templates
-	err = downloadTemplatesFromPath(baseURL+"/Global", "Global", templatesDir)
+// UpdateTemplates replaces the local templates with those of the template
+// source
+func (d *Downloader) UpdateTemplates() error {
+	templatesDir, err := getTemplatesDir()
 	if err != nil {
-		return err
+		return fmt.Errorf("error getting templates directory: %v", err)
 	}
 
-	// Download community templates
-	return downloadTemplatesFromPath(baseURL+"/community", "community", templatesDir)
+	return d.downloadTemplates(templatesDir, true)
 }
 
-// downloadTemplatesFromPath downloads templates from a specific GitHub path
-func downloadTemplatesFromPath(url, prefix, templatesDir string) error {
-	// Get directory listing from GitHub
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+// downloadTemplates downloads all templates into the templates directory.
+// With replace, local templates the source does not have are removed.
+func (d *Downloader) downloadTemplates(templatesDir string, replace bool) error {
+	if hasher, ok := d.Source.(blobHasher); ok {
+		hashes, err := hasher.Hashes()
+		if err == nil {
+			return d.syncTemplates(templatesDir, hashes, replace)
+		}
+		fmt.Printf("Warning: cannot compare the local templates with the source: %v\n", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download templates, status code: %d", resp.StatusCode)
+	if replace {
+		err := removeTemplates(templatesDir)
+		if err != nil {
+			return err
+		}
 	}
 
-	var files []TemplateFile
-	err = json.NewDecoder(resp.Body).Decode(&files)
+	source := d.Source
+	if archiver, ok := source.(archiver); ok {
+		source = archiver.Archive()
+	}
+
+	paths, err := source.List()
 	if err != nil {
 		return err
 	}
 
-	// Create subdirectory if needed
-	if prefix != "" {
-		subDir := filepath.Join(templatesDir, prefix)
-		if _, err := os.Stat(subDir); os.IsNotExist(err) {
-			err = os.Mkdir(subDir, 0755)
-			if err != nil {
-				return err
-			}
+	for _, path := range paths {
+		content, err := source.Fetch(path)
+		if err == nil {
+			err = saveTemplate(templatesDir, path, content)
 		}
-	}
-
-	// Download each file
-	for _, file := range files {
-		if file.Type == "file" && strings.HasSuffix(file.Name, ".gitignore") {
-			var targetPath string
-			if prefix == "" {
-				targetPath = filepath.Join(templatesDir, file.Name)
-			} else {
-				targetPath = filepath.Join(templatesDir, prefix, file.Name)
-			}
-
-			// Download the file
-			err = downloadFile(file.DownloadURL, targetPath)
-			if err != nil {
-				fmt.Printf("Warning: failed to download %s: %v\n", file.Name, err)
-				continue
-			}
-
-			// Give some feedback on progress
-			fmt.Printf("Downloaded %s\n", file.Name)
-
-			// Adding a small delay to avoid hitting rate limits
-			time.Sleep(100 * time.Millisecond)
-		} else if file.Type == "dir" && prefix == "community" {
-			// For community subdirectories, we need to download their contents too
-			subDirURL := url + "/" + file.Name
-			subDirPrefix := prefix + "/" + file.Name
-
-			// Create subdirectory
-			subDir := filepath.Join(templatesDir, subDirPrefix)
-			if _, err := os.Stat(subDir); os.IsNotExist(err) {
-				err = os.Mkdir(subDir, 0755)
-				if err != nil {
-					return err
-				}
-			}
-
-			// Download templates from subdirectory
-			err = downloadTemplatesFromPath(subDirURL, subDirPrefix, templatesDir)
-			if err != nil {
-				fmt.Printf("Warning: failed to download from %s: %v\n", subDirURL, err)
-			}
+		if err != nil {
+			fmt.Printf("Warning: failed to download %s: %v\n", path, err)
+			continue
 		}
+
+		// Give some feedback on progress
+		fmt.Printf("Downloaded %s\n", path)
 	}
 
 	return nil
 }
 
-// downloadFile downloads a file from URL to the specified local path
-func downloadFile(url, targetPath string) error {
-	resp, err := http.Get(url)
+// removeTemplates removes the downloaded templates, keeping the tool's own
+// files such as the block snapshots and the detection rules
+func removeTemplates(templatesDir string) error {
+	entries, err := ioutil.ReadDir(templatesDir)
 	if err != nil {
-		return err
+		return fmt.Errorf("error reading templates directory: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download file, status code: %d", resp.StatusCode)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || (!entry.IsDir() && !strings.HasSuffix(entry.Name(), ".gitignore")) {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(templatesDir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("error removing existing templates: %v", err)
+		}
 	}
+	return nil
+}
 
-	// Create the target file
-	out, err := os.Create(targetPath)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
+// AmbiguousTemplateError is returned when a short template name matches
+// several templates with the same precedence
+type AmbiguousTemplateError struct {
+	Name       string
+	Candidates []string
+}
 
-	// Copy content from response to file
-	_, err = io.Copy(out, resp.Body)
-	return err
+func (e *AmbiguousTemplateError) Error() string {
+	return fmt.Sprintf("template name '%s' is ambiguous, it matches: %s", e.Name, strings.Join(e.Candidates, ", "))
 }
 
 // GetTemplate returns the template for the given framework
 func (t *Templates) GetTemplate(framework string) (string, bool) {
+	name, err := t.ResolveTemplate(framework)
+	if err != nil {
+		return "", false
+	}
+	return t.templates[name], true
+}
+
+// ResolveTemplate returns the full name of the template for the given
+// framework. Names are resolved in this order, and the first step that
+// matches anything decides:
+//  1. the exact name
+//  2. the name ignoring case; if several templates differ only in case, the
+//     one that sorts first byte-wise (upper case before lower case) wins
+//  3. an alias, whose target is then resolved by steps 1 and 4
+//  4. the last path component ignoring case, so "JetBrains" finds
+//     "Global/JetBrains"; root templates win over Global ones over
+//     community ones, and several matches with the same precedence give an
+//     *AmbiguousTemplateError
+//
+// In strict mode every step that matches more than one template gives an
+// *AmbiguousTemplateError instead.
+func (t *Templates) ResolveTemplate(framework string) (string, error) {
+	target, isAlias := t.Aliases[strings.ToLower(framework)]
+	if !isAlias || t.hasName(framework) {
+		return t.resolveName(framework)
+	}
+
+	name, err := t.resolveName(target)
+	if _, ok := err.(*AmbiguousTemplateError); err != nil && !ok {
+		return "", fmt.Errorf("template '%s' (alias for '%s') not found", framework, target)
+	}
+	return name, err
+}
+
+// hasName reports whether a template has exactly this name, ignoring case
+func (t *Templates) hasName(framework string) bool {
+	for name := range t.templates {
+		if strings.EqualFold(name, framework) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveName resolves a template name without consulting aliases
+func (t *Templates) resolveName(framework string) (string, error) {
 	// Try exact match
-	if template, ok := t.templates[framework]; ok {
-		return template, true
+	if _, ok := t.templates[framework]; ok {
+		return framework, nil
 	}
 
 	// Try case-insensitive match
 	lowerFramework := strings.ToLower(framework)
-	for name, template := range t.templates {
+	var caseMatches []string
+	for name := range t.templates {
 		if strings.ToLower(name) == lowerFramework {
-			return template, true
+			caseMatches = append(caseMatches, name)
+		}
+	}
+	sort.Strings(caseMatches)
+	if len(caseMatches) > 1 && t.Strict {
+		return "", &AmbiguousTemplateError{Name: framework, Candidates: caseMatches}
+	}
+	if len(caseMatches) > 0 {
+		return caseMatches[0], nil
+	}
+
+	// Try the short name against the last path component
+	var baseMatches []string
+	for name := range t.templates {
+		if strings.ToLower(path.Base(name)) == lowerFramework {
+			baseMatches = append(baseMatches, name)
 		}
 	}
+	sort.Slice(baseMatches, func(i, j int) bool {
+		pi, pj := templatePrecedence(baseMatches[i]), templatePrecedence(baseMatches[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return baseMatches[i] < baseMatches[j]
+	})
+
+	switch {
+	case len(baseMatches) == 0:
+		return "", fmt.Errorf("template '%s' not found", framework)
+	case len(baseMatches) == 1:
+		return baseMatches[0], nil
+	case t.Strict:
+		return "", &AmbiguousTemplateError{Name: framework, Candidates: baseMatches}
+	}
+
+	// Only the templates with the best precedence compete
+	var candidates []string
+	for _, name := range baseMatches {
+		if templatePrecedence(name) == templatePrecedence(baseMatches[0]) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) > 1 {
+		return "", &AmbiguousTemplateError{Name: framework, Candidates: candidates}
+	}
+	return candidates[0], nil
+}
 
-	// No match found
-	return "", false
+// templatePrecedence ranks where a template lives when resolving short
+// names: root templates first, then Global, then community
+func templatePrecedence(name string) int {
+	switch {
+	case !strings.Contains(name, "/"):
+		return 0
+	case strings.HasPrefix(name, "Global/"):
+		return 1
+	case strings.HasPrefix(name, "community/"):
+		return 2
+	default:
+		return 3
+	}
 }
 
-// ListTemplates returns a list of all available templates
+// ListTemplates returns a sorted list of all available templates
 func (t *Templates) ListTemplates() []string {
 	var templates []string
 	for name := range t.templates {
 		templates = append(templates, name)
 	}
+	sort.Strings(templates)
 	return templates
 }
 
-// WriteGitignore writes the gitignore template to the specified file
-func WriteGitignore(template, outputPath string) error {
-	return ioutil.WriteFile(outputPath, []byte(template), 0644)
-}
-
-// printHelp prints the help information
-func printHelp() {
-	fmt.Println("Gitignore Generator - A tool to create .gitignore files for your projects")
-	fmt.Println()
-	fmt.Println("USAGE:")
-	fmt.Println("  gitignore <command> [arguments] [options]")
-	fmt.Println()
-	fmt.Println("COMMANDS:")
-	fmt.Println("  <framework-name>     Generate a .gitignore file for the specified framework")
-	fmt.Println("  list                 List all available templates")
-	fmt.Println("  update               Update templates from GitHub")
-	fmt.Println("  download-all         Download all templates from GitHub")
-	fmt.Println("  clean                Remove all locally stored templates")
-	fmt.Println("  help, -h, --help     Show this help message")
-	fmt.Println()
-	fmt.Println("OPTIONS:")
-	fmt.Println("  --download-all       When used with a framework name, will download all templates")
-	fmt.Println("                       instead of just the requested one")
-	fmt.Println()
-	fmt.Println("EXAMPLES:")
-	fmt.Println("  gitignore Go                 Create a .gitignore file for Go")
-	fmt.Println("  gitignore Python output.txt  Create a Python .gitignore file named output.txt")
-	fmt.Println("  gitignore list               Show all available templates")
-	fmt.Println("  gitignore download-all       Download all templates from GitHub")
-	fmt.Println()
-	fmt.Println("The templates are stored in ~/.gitignore-cli directory.")
-}
-
-func main() {
-	if len(os.Args) < 2 {
-		printHelp()
-		os.Exit(1)
-	}
+// WriteMode controls what WriteGitignore does with an existing file
+type WriteMode int
 
-	command := strings.ToLower(os.Args[1])
+const (
+	// WriteOverwrite replaces the existing file with the templates
+	WriteOverwrite WriteMode = iota
+	// WriteMerge keeps the existing file and only replaces or appends the
+	// managed template blocks, skipping lines the user already has
+	WriteMerge
+)
 
-	// Check if download-all flag is present
-	downloadAllFlag := false
-	for i := 1; i < len(os.Args); i++ {
-		if os.Args[i] == "--download-all" {
-			downloadAllFlag = true
-			break
+// WriteGitignore writes the templates to the specified file, each wrapped
+// in a managed block. The content written to each block is kept as a
+// snapshot so that later upgrades can merge local edits.
+func WriteGitignore(sections []TemplateSection, outputPath string, mode WriteMode) error {
+	existing := ""
+	if mode == WriteMerge {
+		content, err := ioutil.ReadFile(outputPath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error reading existing file: %v", err)
 		}
+		existing = string(content)
 	}
 
-	// Handle help command
-	if command == "help" || command == "-h" || command == "--help" {
-		printHelp()
-		return
+	file, err := ParseGitignoreFile(existing)
+	if err != nil {
+		return fmt.Errorf("error parsing existing file: %v", err)
 	}
 
-	// Handle clean command
-	if command == "clean" {
-		templatesDir, err := getTemplatesDir()
+	// Only the managed blocks change; everything outside them is kept as is
+	unmanaged := file.Unmanaged()
+	for _, section := range sections {
+		under := normalizeUnder(section.Under)
+		content := missingLines(unmanaged, RebaseRules(section.Content, under))
+		block := file.SetBlock(section.Name, under, content)
+		block.Base, err = saveSnapshot(block.Content)
 		if err != nil {
-			fmt.Printf("Error getting templates directory: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("error saving snapshot: %v", err)
 		}
+	}
 
-		fmt.Print("Are you sure you want to remove all templates? (y/n): ")
-		reader := bufio.NewReader(os.Stdin)
-		response, _ := reader.ReadString('\n')
-		response = strings.TrimSpace(strings.ToLower(response))
+	return ioutil.WriteFile(outputPath, []byte(file.String()), 0644)
+}
 
-		if response != "y" && response != "yes" {
-			fmt.Println("Operation cancelled")
-			return
+// missingLines returns the lines of the template that are not already
+// present in the existing gitignore content. Comments and the order of the
+// remaining lines are kept, and runs of blank lines are collapsed.
+func missingLines(existing, template string) string {
+	present := make(map[string]bool)
+	for _, line := range strings.Split(existing, "\n") {
+		present[strings.TrimRight(line, " \t\r")] = true
+	}
+
+	var added []string
+	for _, line := range strings.Split(template, "\n") {
+		key := strings.TrimRight(line, " \t\r")
+		if key == "" {
+			if len(added) > 0 && added[len(added)-1] != "" {
+				added = append(added, "")
+			}
+			continue
 		}
-
-		err = os.RemoveAll(templatesDir)
-		if err != nil {
-			fmt.Printf("Error removing templates: %v\n", err)
-			os.Exit(1)
+		if present[key] {
+			continue
 		}
+		present[key] = true
+		added = append(added, line)
+	}
 
-		fmt.Println("Templates successfully removed")
-		return
+	// Drop the trailing blank line, if any
+	if len(added) > 0 && added[len(added)-1] == "" {
+		added = added[:len(added)-1]
+	}
+	if len(added) == 0 {
+		return ""
 	}
 
-	// Handle download-all command
-	if command == "download-all" {
-		templatesDir, err := getTemplatesDir()
-		if err != nil {
-			fmt.Printf("Error getting templates directory: %v\n", err)
-			os.Exit(1)
-		}
+	return strings.Join(added, "\n") + "\n"
+}
 
-		fmt.Println("Downloading all templates from GitHub...")
-		err = downloadTemplates(templatesDir)
-		if err != nil {
-			fmt.Printf("Error downloading templates: %v\n", err)
-			os.Exit(1)
+// TemplateSection is one named template inside a combined gitignore file
+type TemplateSection struct {
+	Name    string
+	Content string
+	// Under rebases the template's rules under this directory (optional)
+	Under string
+}
+
+// splitTemplateNames splits comma separated template names (e.g. "Go,Node")
+// and drops empty and duplicate entries
+func splitTemplateNames(args []string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		for _, name := range strings.Split(arg, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[strings.ToLower(name)] {
+				continue
+			}
+			seen[strings.ToLower(name)] = true
+			names = append(names, name)
 		}
+	}
+	return names
+}
+
+// looksLikeOutputPath reports whether a positional argument is a file name
+// rather than a template name. Template names never contain a dot, so
+// "output.txt" or ".gitignore" are treated as output paths.
+func looksLikeOutputPath(arg string) bool {
+	return strings.Contains(filepath.Base(arg), ".")
+}
+
+// generateArgs are the parsed arguments of a generate call
+type generateArgs struct {
+	Names      []string
+	OutputPath string
+	// Under rebases the templates under this directory
+	Under string
+}
+
+// parseGenerateArgs works out the template names, the output path and
+// options of a generate call
+func parseGenerateArgs(inv *Invocation) (generateArgs, error) {
+	parsed := generateArgs{Under: inv.String("under", "")}
+	outputPath := inv.String("output", "")
+	positional := inv.Args
+
+	// Keep supporting the old "gitignore <framework> <output>" form
+	if outputPath == "" && len(positional) > 1 && looksLikeOutputPath(positional[len(positional)-1]) {
+		outputPath = positional[len(positional)-1]
+		positional = positional[:len(positional)-1]
+	}
+	if outputPath == "" {
+		outputPath = ".gitignore"
+	}
+
+	parsed.Names = splitTemplateNames(positional)
+	if len(parsed.Names) == 0 {
+		return parsed, fmt.Errorf("no template names given")
+	}
+	parsed.OutputPath = outputPath
+
+	return parsed, nil
+}
+
+// loadMatcher reads a gitignore file and creates a matcher for its rules
+func loadMatcher(gitignorePath string) (*Matcher, error) {
+	content, err := ioutil.ReadFile(gitignorePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewMatcher(ParseRules(string(content))), nil
+}
+
+// matchPath turns a path given on the command line into the path relative
+// to the directory of the gitignore file, and reports whether it is a
+// directory. A trailing slash marks a directory that does not exist.
+func matchPath(gitignorePath, p string) (string, bool, error) {
+	baseDir, err := filepath.Abs(filepath.Dir(gitignorePath))
+	if err != nil {
+		return "", false, err
+	}
+	absPath, err := filepath.Abs(p)
+	if err != nil {
+		return "", false, err
+	}
+
+	rel, err := filepath.Rel(baseDir, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false, fmt.Errorf("'%s' is outside of '%s'", p, baseDir)
+	}
+
+	isDir := strings.HasSuffix(p, "/") || strings.HasSuffix(p, string(filepath.Separator))
+	if info, err := os.Stat(p); err == nil {
+		isDir = info.IsDir()
+	}
 
-		fmt.Println("All templates downloaded successfully!")
+	return filepath.ToSlash(rel), isDir, nil
+}
+
+// printExplanation prints which rule decides whether a path is ignored
+func printExplanation(gitignorePath, p string, explanation Explanation) {
+	result := explanation.Result
+	switch {
+	case !result.Matched:
+		fmt.Printf("'%s' is not ignored: no rule matches it\n", p)
 		return
+	case result.Parent != "":
+		fmt.Printf("'%s' is ignored because its parent directory '%s' is excluded\n", p, result.Parent)
+	case result.Ignored:
+		fmt.Printf("'%s' is ignored\n", p)
+	default:
+		fmt.Printf("'%s' is not ignored: it is re-included by a negated rule\n", p)
 	}
 
-	if command == "update" {
-		// Force update templates
-		templatesDir, err := getTemplatesDir()
-		if err != nil {
-			fmt.Printf("Error getting templates directory: %v\n", err)
-			os.Exit(1)
-		}
+	fmt.Printf("  rule:   %s\n", strings.TrimSpace(result.Rule.Raw))
+	fmt.Printf("  source: %s\n", ruleSource(gitignorePath, result.Rule, explanation.Template))
 
-		// Remove existing templates
-		err = os.RemoveAll(templatesDir)
-		if err != nil {
-			fmt.Printf("Error removing existing templates: %v\n", err)
-			os.Exit(1)
+	if len(explanation.Overridden) > 0 {
+		fmt.Println("  overrides:")
+		for _, overridden := range explanation.Overridden {
+			fmt.Printf("    %-30s %s\n", strings.TrimSpace(overridden.Rule.Raw), ruleSource(gitignorePath, overridden.Rule, overridden.Template))
 		}
+	}
+}
 
-		// Create templates directory again
-		err = os.Mkdir(templatesDir, 0755)
-		if err != nil {
-			fmt.Printf("Error creating templates directory: %v\n", err)
-			os.Exit(1)
-		}
+// ruleSource describes where a rule comes from, e.g. ".gitignore:12 (template Go)"
+func ruleSource(gitignorePath string, rule Rule, template string) string {
+	if template == "" {
+		return fmt.Sprintf("%s:%d (outside template blocks)", gitignorePath, rule.Line)
+	}
+	return fmt.Sprintf("%s:%d (template %s)", gitignorePath, rule.Line, template)
+}
 
-		// Download templates
-		fmt.Println("Updating templates from GitHub...")
-		err = downloadTemplates(templatesDir)
-		if err != nil {
-			fmt.Printf("Error downloading templates: %v\n", err)
-			os.Exit(1)
-		}
+// hasManagedBlocks reports whether the file at path contains managed template blocks
+func hasManagedBlocks(path string) bool {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	file, err := ParseGitignoreFile(string(content))
+	return err == nil && len(file.Blocks()) > 0
+}
 
-		fmt.Println("Templates updated successfully!")
-		return
+func main() {
+	commands := commandTable()
+	if len(os.Args) < 2 {
+		printHelp(commands)
+		os.Exit(exitError)
 	}
 
-	// Initialize and load templates
-	templates := NewTemplates()
-	err := templates.LoadTemplates()
+	inv, err := ParseCommandLine(commands, os.Args[1:])
 	if err != nil {
-		fmt.Printf("Error loading templates: %v\n", err)
-		os.Exit(1)
-	}
-
-	if command == "list" {
-		// List all available templates
-		templateList := templates.ListTemplates()
-		fmt.Printf("Available templates (%d):\n", len(templateList))
-
-		// Group templates by directory
-		groups := make(map[string][]string)
-		for _, t := range templateList {
-			if strings.Contains(t, "/") {
-				parts := strings.SplitN(t, "/", 2)
-				groups[parts[0]] = append(groups[parts[0]], parts[1])
-			} else {
-				groups["Main"] = append(groups["Main"], t)
-			}
-		}
+		fmt.Printf("Error: %v\n", err)
+		fmt.Println("Run 'gitignore help' for usage")
+		os.Exit(exitUsage)
+	}
 
-		// Print templates by group
-		for group, templates := range groups {
-			fmt.Printf("\n%s:\n", group)
-			for _, t := range templates {
-				fmt.Printf("  - %s\n", t)
-			}
-		}
+	if inv.Bool("help") && inv.Path != "help" {
+		printCommandHelp(inv.Path, inv.Command)
 		return
 	}
+	inv.Command.Run(inv)
+}
 
-	// Get the requested template
-	templateContent := ""
-	found := false
+// printSuggestions prints the local templates closest to a name that was
+// not found
+func printSuggestions(templates *Templates, name string) {
+	suggestions := templates.Suggest(name, 3)
+	if len(suggestions) == 0 {
+		return
+	}
+	fmt.Printf("Did you mean: %s?\n", strings.Join(suggestions, ", "))
+}
 
-	// If download-all flag is present, always download all templates
-	if downloadAllFlag {
-		templatesDir, err := getTemplatesDir()
-		if err != nil {
-			fmt.Printf("Error getting templates directory: %v\n", err)
-			os.Exit(1)
-		}
+// fetchTemplate returns the full name and content of a template from the
+// local cache, downloading just that template if it is not there
+func fetchTemplate(templates *Templates, name string) (string, string, error) {
+	resolved, err := templates.ResolveTemplate(name)
+	if err == nil {
+		templateContent, _ := templates.GetTemplate(resolved)
+		return resolved, templateContent, nil
+	}
 
-		fmt.Println("Downloading all templates from GitHub...")
-		err = downloadTemplates(templatesDir)
-		if err != nil {
-			fmt.Printf("Error downloading templates: %v\n", err)
-			os.Exit(1)
-		}
+	// An ambiguous name is on disk already; downloading would not help
+	if _, ok := err.(*AmbiguousTemplateError); ok {
+		return "", "", err
+	}
 
-		// Reload templates
-		templates = NewTemplates()
-		err = templates.LoadTemplates()
-		if err != nil {
-			fmt.Printf("Error loading templates: %v\n", err)
-			os.Exit(1)
+	fmt.Printf("Template for '%s' not found locally. Trying to download...\n", name)
+	resolved, templateContent, err := templates.Downloader.DownloadSingleTemplate(name)
+	if err != nil {
+		return "", "", err
+	}
+	fmt.Printf("Template for '%s' downloaded successfully\n", name)
+
+	return resolved, templateContent, nil
+}
+
+// generateGitignore resolves the templates, downloading missing ones, and
+// writes them to outputPath followed by the extra sections, rebased under
+// the given directory if it is not empty. It exits the program on errors.
+func generateGitignore(inv *Invocation, templates *Templates, names []string, extras []TemplateSection, outputPath, under string) {
+	downloadAllFlag := inv.Bool("download-all")
+	// Get each requested template
+	var sections []TemplateSection
+	for _, name := range names {
+		var resolved, templateContent string
+		var err error
+		if downloadAllFlag {
+			// Everything was just downloaded, so there is nothing to fall back to
+			resolved, err = templates.ResolveTemplate(name)
+			if err == nil {
+				templateContent, _ = templates.GetTemplate(resolved)
+			}
+		} else {
+			resolved, templateContent, err = fetchTemplate(templates, name)
 		}
 
-		templateContent, found = templates.GetTemplate(os.Args[1])
-	} else {
-		// Try to get template from local cache first
-		templateContent, found = templates.GetTemplate(os.Args[1])
-
-		// If not found locally, try to download just this template
-		if !found {
-			fmt.Printf("Template for '%s' not found locally. Trying to download...\n", os.Args[1])
-			var err error
-			templateContent, err = DownloadSingleTemplate(os.Args[1])
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				fmt.Println("Try 'gitignore list' to see available templates")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			printSuggestions(templates, name)
+			fmt.Println("Try 'gitignore list' to see available templates")
+			if !downloadAllFlag {
 				fmt.Println("or 'gitignore download-all' to download all templates")
-				os.Exit(1)
 			}
-			found = true
-			fmt.Printf("Template for '%s' downloaded successfully\n", os.Args[1])
+			os.Exit(exitError)
 		}
-	}
-
-	if !found {
-		fmt.Printf("No template found for '%s'\n", os.Args[1])
-		fmt.Println("Try 'gitignore list' to see all available templates")
-		os.Exit(1)
-	}
 
-	// Write to .gitignore in current directory
-	outputPath := ".gitignore"
-	if len(os.Args) > 2 && os.Args[2] != "--download-all" {
-		outputPath = os.Args[2]
+		sections = append(sections, TemplateSection{Name: resolved, Content: templateContent, Under: under})
 	}
+	sections = append(sections, extras...)
 
-	// Check if file exists and confirm overwrite
-	if _, err := os.Stat(outputPath); err == nil {
-		fmt.Printf("File '%s' already exists. Overwrite? (y/n): ", outputPath)
-		reader := bufio.NewReader(os.Stdin)
-		response, _ := reader.ReadString('\n')
-		response = strings.TrimSpace(strings.ToLower(response))
-		if response != "y" && response != "yes" {
+	// Check if file exists and ask whether to merge or overwrite. Files that
+	// already contain managed blocks are always updated in place.
+	mode := WriteOverwrite
+	if inv.Bool("merge") || hasManagedBlocks(outputPath) {
+		mode = WriteMerge
+	} else if _, err := os.Stat(outputPath); err == nil && !inv.AssumeYes() {
+		response, err := inv.Ask(fmt.Sprintf("File '%s' already exists. (m)erge, (o)verwrite or (c)ancel? ", outputPath))
+		if err != nil {
+			fmt.Printf("Error: file '%s' already exists and %v\n", outputPath, err)
+			fmt.Println("Use --merge to merge into it or --yes to overwrite it")
+			os.Exit(exitError)
+		}
+		switch response {
+		case "m", "merge":
+			mode = WriteMerge
+		case "o", "overwrite", "y", "yes":
+			mode = WriteOverwrite
+		default:
 			fmt.Println("Operation cancelled")
-			os.Exit(0)
+			os.Exit(exitCancelled)
 		}
 	}
 
-	err = WriteGitignore(templateContent, outputPath)
+	err := WriteGitignore(sections, outputPath, mode)
 	if err != nil {
 		fmt.Printf("Error writing gitignore: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
-	fmt.Printf("Successfully created gitignore for '%s' at '%s'\n", os.Args[1], outputPath)
+	if mode == WriteMerge {
+		fmt.Printf("Successfully updated gitignore for '%s' in '%s'\n", strings.Join(names, ", "), outputPath)
+		return
+	}
+	fmt.Printf("Successfully created gitignore for '%s' at '%s'\n", strings.Join(names, ", "), outputPath)
 }
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -181,9 +182,9 @@ func TestLoadTemplatesFromDir(t *testing.T) {
 
 	// Load templates from directory
 	templates := NewTemplates()
-	err = loadTemplatesFromDir(templates, tempDir, "")
+	err = templates.LoadFrom(&DirSource{Dir: tempDir})
 	if err != nil {
-		t.Fatalf("loadTemplatesFromDir returned error: %v", err)
+		t.Fatalf("LoadFrom returned error: %v", err)
 	}
 
 	// Check templates were loaded correctly
@@ -218,13 +219,18 @@ func TestLoadTemplatesFromDir(t *testing.T) {
 func TestDownloadSingleTemplate(t *testing.T) {
 	// Create a mock HTTP server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/repos/github/gitignore/contents/community" {
-			// Return mock community directory listing
+		if r.URL.Path == "/repos/github/gitignore/git/trees/main" {
+			// Return a mock listing of the repository
 			w.Header().Set("Content-Type", "application/json")
-			w.Write([]byte(`[
-				{"name": "JavaScript", "type": "dir", "path": "community/JavaScript"},
-				{"name": "Python", "type": "dir", "path": "community/Python"}
-			]`))
+			w.Write([]byte(`{"sha": "abc", "truncated": false, "tree": [
+				{"path": "Go.gitignore", "type": "blob", "sha": "1"},
+				{"path": "Global", "type": "tree", "sha": "2"},
+				{"path": "Global/JetBrains.gitignore", "type": "blob", "sha": "3"},
+				{"path": "community/JavaScript", "type": "tree", "sha": "4"},
+				{"path": "community/JavaScript/Node.gitignore", "type": "blob", "sha": "5"},
+				{"path": "community/Go/Hugo.gitignore", "type": "blob", "sha": "6"},
+				{"path": "community/JavaScript/Hugo.gitignore", "type": "blob", "sha": "7"}
+			]}`))
 			return
 		}
 
@@ -251,47 +257,449 @@ func TestDownloadSingleTemplate(t *testing.T) {
 	}))
 	defer server.Close()
 
-	// Save the original URL constants
-	originalGithubAPI := "https://api.github.com"
-	originalGithubRaw := "https://raw.githubusercontent.com"
+	// Point the downloader at the mock server
+	tempDir, err := ioutil.TempDir("", "gitignore-download-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
 
-	// Replace with our mock server URL
-	// This is a hack since we don't have the URLs as variables
-	// In a real codebase, you'd make these configurable
+	downloader := &Downloader{
+		Source:  &GitHubSource{Upstream: defaultUpstream.Override(Upstream{APIURL: server.URL, RawURL: server.URL}), Client: server.Client()},
+		Aliases: map[string]string{"js": "Node"},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"Go", "Go.gitignore", "# Go gitignore template\n*.exe\n"},
+		{"JetBrains", "Global/JetBrains.gitignore", "# JetBrains gitignore template\n.idea/\n"},
+		{"jetbrains", "Global/JetBrains.gitignore", "# JetBrains gitignore template\n.idea/\n"},
+		{"js", "community/JavaScript/Node.gitignore", "# Node gitignore template\nnode_modules/\n"},
+	}
+
+	for _, test := range tests {
+		name, content, err := downloader.DownloadSingleTemplate(test.name)
+		if err != nil {
+			t.Errorf("DownloadSingleTemplate(%q) returned error: %v", test.name, err)
+			continue
+		}
+		if name+".gitignore" != test.path {
+			t.Errorf("DownloadSingleTemplate(%q) name = %q, expected the path %q", test.name, name, test.path)
+		}
+		if content != test.expected {
+			t.Errorf("DownloadSingleTemplate(%q) = %q, expected %q", test.name, content, test.expected)
+		}
+
+		// The template is saved for future use
+		saved, err := ioutil.ReadFile(filepath.Join(tempDir, ".gitignore-cli", filepath.FromSlash(test.path)))
+		if err != nil {
+			t.Errorf("Expected %s to be saved: %v", test.path, err)
+		} else if string(saved) != test.expected {
+			t.Errorf("Saved content mismatch for %s", test.path)
+		}
+	}
+
+	if _, _, err := downloader.DownloadSingleTemplate("Missing"); err == nil {
+		t.Error("Expected an error for a template that does not exist")
+	}
+	if _, _, err := downloader.DownloadSingleTemplate("hugo"); err == nil {
+		t.Error("Expected an error for a name in several directories")
+	} else if ambiguous, ok := err.(*AmbiguousTemplateError); !ok || len(ambiguous.Candidates) != 2 {
+		t.Errorf("Expected an AmbiguousTemplateError with both candidates, got %v", err)
+	}
+}
+
+// TestDownloadTemplates tests downloading every template of the repository
+// as one archive when it cannot be listed
+func TestDownloadTemplates(t *testing.T) {
+	archive := makeTarball(t, map[string]string{
+		"acme-templates-1a2b3c/Go.gitignore":                       "# Go.gitignore\n",
+		"acme-templates-1a2b3c/Global/macOS.gitignore":             "# Global/macOS.gitignore\n",
+		"acme-templates-1a2b3c/community/JavaScript/Vue.gitignore": "# community/JavaScript/Vue.gitignore\n",
+		"acme-templates-1a2b3c/README.md":                          "# Templates\n",
+		"acme-templates-1a2b3c/.github/Bot.gitignore":              "# not a template\n",
+		"acme-templates-1a2b3c/docs/Example.gitignore":             "# not a template\n",
+	})
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path == "/repos/acme/templates/tarball/stable" {
+			w.Write(archive)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
 
-	// Create a temporary directory for test templates
 	tempDir, err := ioutil.TempDir("", "gitignore-download-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Skip actual download test since we can't easily modify the URLs
-	// In a real test, you'd mock the HTTP client or make the URLs configurable
-	t.Skip("Skipping download test since it requires mocking HTTP requests")
+	downloader := &Downloader{Source: &GitHubSource{
+		Upstream: Upstream{APIURL: server.URL, RawURL: server.URL + "/raw", Repo: "acme/templates", Branch: "stable"},
+		Client:   server.Client(),
+	}}
 
-	// Instead, we'll test just the local template loading after download
-	goContent := "# Go gitignore template\n*.exe\n"
-	goDir := filepath.Join(tempDir)
-	err = os.MkdirAll(goDir, 0755)
+	err = downloader.DownloadTemplates(tempDir)
 	if err != nil {
-		t.Fatalf("Failed to create directory: %v", err)
+		t.Fatalf("DownloadTemplates returned error: %v", err)
+	}
+
+	for _, path := range []string{"Go.gitignore", "Global/macOS.gitignore", "community/JavaScript/Vue.gitignore"} {
+		content, err := ioutil.ReadFile(filepath.Join(tempDir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Errorf("Expected %s to be downloaded: %v", path, err)
+		} else if string(content) != "# "+path+"\n" {
+			t.Errorf("Content mismatch for %s: %q", path, content)
+		}
+	}
+	for _, path := range []string{"README.md", ".github", "docs", "acme-templates-1a2b3c"} {
+		if _, err := os.Stat(filepath.Join(tempDir, path)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be skipped", path)
+		}
+	}
+	var archiveRequests int
+	for _, request := range requests {
+		if strings.HasPrefix(request, "/raw/") {
+			t.Errorf("Expected no single file downloads, got %s", request)
+		}
+		if request == "/repos/acme/templates/tarball/stable" {
+			archiveRequests++
+		}
 	}
-	goFile := filepath.Join(goDir, "Go.gitignore")
-	err = ioutil.WriteFile(goFile, []byte(goContent), 0644)
+	if archiveRequests != 1 {
+		t.Errorf("Expected a single request for the archive, got %v", requests)
+	}
+}
+
+// TestUpstreamPrecedence tests that flags override environment variables,
+// which override the config file
+func TestUpstreamPrecedence(t *testing.T) {
+	config := Config{Upstream: &Upstream{APIURL: "https://config.example", Repo: "config/repo", Branch: "config"}}
+	t.Setenv("GETIGNORE_REPO", "env/repo")
+	t.Setenv("GETIGNORE_BRANCH", "env")
+	t.Setenv("GETIGNORE_SOURCE", "")
+
+	inv, err := ParseCommandLine(commandTable(), []string{"update", "--branch", "flag"})
+	if err != nil {
+		t.Fatalf("ParseCommandLine returned error: %v", err)
+	}
+
+	expected := Upstream{
+		APIURL: "https://config.example",
+		RawURL: defaultUpstream.RawURL,
+		Repo:   "env/repo",
+		Branch: "flag",
+	}
+	if upstream := newDownloader(inv, config).Source.(*GitHubSource).Upstream; upstream != expected {
+		t.Errorf("Expected upstream %+v, got %+v", expected, upstream)
+	}
+}
+
+// TestWriteGitignore tests writing several templates into one file
+func TestWriteGitignore(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-write-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
+
+	outputPath := filepath.Join(tempDir, ".gitignore")
+	err = ioutil.WriteFile(outputPath, []byte("/old\n"), 0644)
 	if err != nil {
 		t.Fatalf("Failed to create file: %v", err)
 	}
 
-	// Create a templates instance and load from our test dir
+	sections := []TemplateSection{
+		{Name: "Go", Content: "*.exe\n"},
+		{Name: "Node", Content: "node_modules/\n\n"},
+	}
+	err = WriteGitignore(sections, outputPath, WriteOverwrite)
+	if err != nil {
+		t.Fatalf("WriteGitignore returned error: %v", err)
+	}
+
+	content, err := ioutil.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	expected := "# >>> getignore: Go base=" + contentHash("*.exe\n") + "\n*.exe\n# <<< getignore: Go\n\n" +
+		"# >>> getignore: Node base=" + contentHash("node_modules/\n") + "\nnode_modules/\n# <<< getignore: Node\n"
+	if string(content) != expected {
+		t.Errorf("Written content mismatch. Expected '%s', got '%s'", expected, string(content))
+	}
+}
+
+// TestParseGenerateArgs tests splitting generate arguments into names and output path
+func TestParseGenerateArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		names  []string
+		output string
+		under  string
+	}{
+		{[]string{"Go"}, []string{"Go"}, ".gitignore", ""},
+		{[]string{"Python", "output.txt"}, []string{"Python"}, "output.txt", ""},
+		{[]string{"Go", "Node", "Global/JetBrains"}, []string{"Go", "Node", "Global/JetBrains"}, ".gitignore", ""},
+		{[]string{"Go,Node", "--download-all"}, []string{"Go", "Node"}, ".gitignore", ""},
+		{[]string{"Go", "-o", "out", "go"}, []string{"Go"}, "out", ""},
+		{[]string{"Node", "--under", "web/"}, []string{"Node"}, ".gitignore", "web/"},
+		{[]string{"Go", "--no-defaults", "--strict"}, []string{"Go"}, ".gitignore", ""},
+		{[]string{"--output=out", "Go"}, []string{"Go"}, "out", ""},
+		{[]string{"generate", "list", "update"}, []string{"list", "update"}, ".gitignore", ""},
+		{[]string{"-o", "out", "--", "list", "-x"}, []string{"list", "-x"}, "out", ""},
+	}
+
+	for _, test := range tests {
+		inv, err := ParseCommandLine(commandTable(), test.args)
+		if err != nil {
+			t.Errorf("ParseCommandLine(%v) returned error: %v", test.args, err)
+			continue
+		}
+		args, err := parseGenerateArgs(inv)
+		if err != nil {
+			t.Errorf("parseGenerateArgs(%v) returned error: %v", test.args, err)
+			continue
+		}
+		if strings.Join(args.Names, ",") != strings.Join(test.names, ",") {
+			t.Errorf("parseGenerateArgs(%v) names = %v, expected %v", test.args, args.Names, test.names)
+		}
+		if args.OutputPath != test.output {
+			t.Errorf("parseGenerateArgs(%v) output = '%s', expected '%s'", test.args, args.OutputPath, test.output)
+		}
+		if args.Under != test.under {
+			t.Errorf("parseGenerateArgs(%v) under = '%s', expected '%s'", test.args, args.Under, test.under)
+		}
+	}
+
+	if _, err := ParseCommandLine(commandTable(), []string{"--download-all"}); err == nil {
+		t.Error("Expected an error when no template names are given")
+	}
+	inv, err := ParseCommandLine(commandTable(), []string{"--", ","})
+	if err != nil {
+		t.Fatalf("ParseCommandLine returned error: %v", err)
+	}
+	if _, err := parseGenerateArgs(inv); err == nil {
+		t.Error("Expected an error when no template names are given")
+	}
+}
+
+// TestMissingLines tests filtering template lines that are already present
+func TestMissingLines(t *testing.T) {
+	existing := "# Project rules\n/build\n*.log\n"
+	template := "# Logs\n*.log\n\n# Binaries\n*.exe\n/build\n"
+
+	expected := "# Logs\n\n# Binaries\n*.exe\n"
+	if missing := missingLines(existing, template); missing != expected {
+		t.Errorf("Missing lines mismatch. Expected '%s', got '%s'", expected, missing)
+	}
+
+	// Nothing is missing when every line is present
+	if missing := missingLines(existing, "*.log\n\n/build\n"); missing != "" {
+		t.Errorf("Expected no missing lines, got '%s'", missing)
+	}
+}
+
+// TestShortNameResolution tests resolving bare names against Global and community templates
+func TestShortNameResolution(t *testing.T) {
 	templates := NewTemplates()
-	templates.loadTemplate(goFile, "Go")
+	templates.templates["Node"] = "root node"
+	templates.templates["community/JavaScript/Node"] = "community node"
+	templates.templates["Global/JetBrains"] = "jetbrains"
+	templates.templates["community/JavaScript/Vue"] = "vue"
+	templates.templates["community/Golang/Hugo"] = "golang hugo"
+	templates.templates["community/Static/Hugo"] = "static hugo"
+
+	tests := []struct {
+		name     string
+		resolved string
+	}{
+		{"Node", "Node"},
+		{"JetBrains", "Global/JetBrains"},
+		{"jetbrains", "Global/JetBrains"},
+		{"Vue", "community/JavaScript/Vue"},
+		{"community/JavaScript/Node", "community/JavaScript/Node"},
+	}
 
-	// Test if template was loaded correctly
-	template, found := templates.GetTemplate("go") // Test case-insensitive matching
-	if !found {
-		t.Error("Failed to get Go template")
-	} else if template != goContent {
-		t.Errorf("Template content mismatch")
+	for _, test := range tests {
+		resolved, err := templates.ResolveTemplate(test.name)
+		if err != nil {
+			t.Errorf("ResolveTemplate(%q) returned error: %v", test.name, err)
+		} else if resolved != test.resolved {
+			t.Errorf("ResolveTemplate(%q) = %q, expected %q", test.name, resolved, test.resolved)
+		}
+	}
+
+	// Two community templates with the same precedence are ambiguous
+	_, err := templates.ResolveTemplate("hugo")
+	ambiguous, ok := err.(*AmbiguousTemplateError)
+	if !ok {
+		t.Fatalf("Expected an AmbiguousTemplateError, got %v", err)
+	}
+	if strings.Join(ambiguous.Candidates, ",") != "community/Golang/Hugo,community/Static/Hugo" {
+		t.Errorf("Unexpected candidates %v", ambiguous.Candidates)
+	}
+
+	if _, found := templates.GetTemplate("Missing"); found {
+		t.Error("Expected Missing to be not found")
+	}
+}
+
+// TestDeterministicResolution tests that colliding names always resolve the same way
+func TestDeterministicResolution(t *testing.T) {
+	templates := NewTemplates()
+	templates.templates["Go"] = "Go"
+	templates.templates["GO"] = "GO"
+	templates.templates["go"] = "go"
+	templates.templates["Global/Hugo"] = "global"
+	templates.templates["community/Golang/Hugo"] = "community"
+
+	// Run several times since map iteration order is random
+	for i := 0; i < 20; i++ {
+		if resolved, err := templates.ResolveTemplate("gO"); err != nil || resolved != "GO" {
+			t.Fatalf("ResolveTemplate(gO) = %q, %v; expected GO", resolved, err)
+		}
+		if resolved, err := templates.ResolveTemplate("hugo"); err != nil || resolved != "Global/Hugo" {
+			t.Fatalf("ResolveTemplate(hugo) = %q, %v; expected Global/Hugo", resolved, err)
+		}
+	}
+
+	// Exact matches are never ambiguous
+	templates.Strict = true
+	if resolved, err := templates.ResolveTemplate("go"); err != nil || resolved != "go" {
+		t.Errorf("ResolveTemplate(go) = %q, %v; expected go", resolved, err)
+	}
+
+	// Strict mode refuses to pick between case variants or directories
+	for _, name := range []string{"gO", "hugo"} {
+		if _, err := templates.ResolveTemplate(name); err == nil {
+			t.Errorf("Expected ResolveTemplate(%q) to fail in strict mode", name)
+		} else if _, ok := err.(*AmbiguousTemplateError); !ok {
+			t.Errorf("Expected an AmbiguousTemplateError for %q, got %v", name, err)
+		}
+	}
+}
+
+// TestAliasResolution tests resolving aliases to template names
+func TestAliasResolution(t *testing.T) {
+	templates := NewTemplates()
+	templates.templates["Go"] = "Go"
+	templates.templates["Vim"] = "Vim"
+	templates.templates["Global/JetBrains"] = "JetBrains"
+	templates.templates["Global/Vim"] = "Global Vim"
+	templates.Aliases = map[string]string{
+		"golang": "Go",
+		"idea":   "JetBrains",
+		"vim":    "Global/Vim",
+		"rs":     "Rust",
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"golang", "Go"},
+		{"GoLang", "Go"},
+		// Alias targets may be short names themselves
+		{"idea", "Global/JetBrains"},
+		// A template with the name wins over an alias
+		{"vim", "Vim"},
+	}
+
+	for _, test := range tests {
+		if resolved, err := templates.ResolveTemplate(test.name); err != nil || resolved != test.expected {
+			t.Errorf("ResolveTemplate(%q) = %q, %v; expected %q", test.name, resolved, err, test.expected)
+		}
+	}
+
+	_, err := templates.ResolveTemplate("rs")
+	if err == nil || !strings.Contains(err.Error(), "alias for 'Rust'") {
+		t.Errorf("Expected a not found error naming the alias target, got %v", err)
+	}
+}
+
+// TestClean tests that clean removes the downloaded templates but keeps the
+// tool's own files
+func TestClean(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-clean-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
+
+	templatesDir := filepath.Join(tempDir, ".gitignore-cli")
+	kept := map[string]string{
+		configFileName:          `{"presets": {"backend": {"templates": ["Go", "Node"]}}}`,
+		"detect.json":           `{"rules": []}`,
+		snapshotsDirName + "/a": "# snapshot\n",
+	}
+	writeFiles(t, templatesDir, kept)
+	writeFiles(t, templatesDir, map[string]string{
+		"Go.gitignore":                       "*.exe\n",
+		"Global/macOS.gitignore":             ".DS_Store\n",
+		"community/JavaScript/Vue.gitignore": "dist/\n",
+	})
+
+	inv, err := ParseCommandLine(commandTable(), []string{"clean", "-y"})
+	if err != nil {
+		t.Fatalf("ParseCommandLine returned error: %v", err)
+	}
+	runClean(inv)
+
+	for path, expected := range kept {
+		content, err := ioutil.ReadFile(filepath.Join(templatesDir, filepath.FromSlash(path)))
+		if err != nil || string(content) != expected {
+			t.Errorf("Expected %s to be kept, got %q (%v)", path, content, err)
+		}
+	}
+	for _, path := range []string{"Go.gitignore", "Global", "community"} {
+		if _, err := os.Stat(filepath.Join(templatesDir, path)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+}
+
+// TestGenerateDownloadedName tests that a downloaded template's block is
+// named like the cached template, so a later run does not add it again
+func TestGenerateDownloadedName(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-generate-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
+
+	sourceDir := filepath.Join(tempDir, "source")
+	writeFiles(t, sourceDir, map[string]string{
+		"Go.gitignore":               "*.exe\n",
+		"Global/JetBrains.gitignore": ".idea/\n",
+	})
+	outputPath := filepath.Join(tempDir, "project", ".gitignore")
+	os.MkdirAll(filepath.Dir(outputPath), 0755)
+
+	// The first run downloads JetBrains, the second finds it in the cache
+	for _, names := range [][]string{{"JetBrains"}, {"Global/JetBrains", "Go"}} {
+		args := append([]string{"--source", "dir:" + sourceDir, "-o", outputPath, "-y"}, names...)
+		inv, err := ParseCommandLine(commandTable(), args)
+		if err != nil {
+			t.Fatalf("ParseCommandLine returned error: %v", err)
+		}
+		runGenerate(inv)
+	}
+
+	var blocks []string
+	for _, block := range readGitignoreFile(t, outputPath).Blocks() {
+		blocks = append(blocks, block.Label())
+	}
+	if strings.Join(blocks, ",") != "Global/JetBrains,Go" {
+		t.Errorf("Expected one block per template, got %v", blocks)
 	}
 }
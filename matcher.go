@@ -0,0 +1,168 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// Matcher decides whether paths are ignored by a list of gitignore rules,
+// following git's semantics:
+//   - the last matching rule wins, and a negated rule re-includes the path
+//   - directory-only rules ("build/") only match directories
+//   - a path inside an excluded directory stays ignored, because git never
+//     looks inside that directory, even if a later rule would re-include it
+type Matcher struct {
+	rules []Rule
+}
+
+// MatchResult describes how a path was matched
+type MatchResult struct {
+	// Ignored reports whether the path is ignored
+	Ignored bool
+	// Matched reports whether any rule decided the outcome; when false the
+	// path is simply not mentioned by any rule
+	Matched bool
+	// Rule is the rule that decided the outcome
+	Rule Rule
+	// Parent is set when the path is ignored because this parent directory
+	// is excluded
+	Parent string
+}
+
+// NewMatcher creates a matcher for the pattern rules in the list
+func NewMatcher(list *RuleList) *Matcher {
+	return &Matcher{rules: list.Patterns()}
+}
+
+// IsIgnored reports whether the path is ignored
+func (m *Matcher) IsIgnored(name string, isDir bool) bool {
+	return m.Match(name, isDir).Ignored
+}
+
+// Match checks a slash separated path, relative to the directory of the
+// gitignore file, against the rules
+func (m *Matcher) Match(name string, isDir bool) MatchResult {
+	name = normalizeMatchPath(name)
+	if name == "" {
+		return MatchResult{}
+	}
+
+	// An excluded parent directory cannot be re-included from inside
+	segments := strings.Split(name, "/")
+	for i := 1; i < len(segments); i++ {
+		parent := strings.Join(segments[:i], "/")
+		if rule, ok := m.lastMatch(parent, true); ok && !rule.Negate {
+			return MatchResult{Ignored: true, Matched: true, Rule: rule, Parent: parent}
+		}
+	}
+
+	rule, ok := m.lastMatch(name, isDir)
+	if !ok {
+		return MatchResult{}
+	}
+	return MatchResult{Ignored: !rule.Negate, Matched: true, Rule: rule}
+}
+
+// Matches returns every rule matching the path itself, in file order. The
+// last one is the rule that wins unless a parent directory is excluded.
+func (m *Matcher) Matches(name string, isDir bool) []Rule {
+	name = normalizeMatchPath(name)
+	var matches []Rule
+	for _, rule := range m.rules {
+		if name != "" && ruleMatches(rule, name, isDir) {
+			matches = append(matches, rule)
+		}
+	}
+	return matches
+}
+
+// lastMatch returns the last rule matching the path itself
+func (m *Matcher) lastMatch(name string, isDir bool) (Rule, bool) {
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		if ruleMatches(m.rules[i], name, isDir) {
+			return m.rules[i], true
+		}
+	}
+	return Rule{}, false
+}
+
+// ruleMatches reports whether a single pattern rule matches the path
+func ruleMatches(rule Rule, name string, isDir bool) bool {
+	if rule.Kind != RulePattern || rule.Pattern == "" {
+		return false
+	}
+	if rule.DirOnly && !isDir {
+		return false
+	}
+
+	// Patterns without a slash match the last path component at any depth
+	if !rule.Anchored {
+		return matchSegment(rule.Pattern, path.Base(name))
+	}
+
+	return matchSegments(rule.Segments, strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches any number of directories
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		// A trailing "/**" matches everything inside, but not the
+		// directory itself
+		if len(pattern) == 1 {
+			return len(segments) > 0
+		}
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 || !matchSegment(pattern[0], segments[0]) {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// matchSegment matches a single path component against a glob supporting
+// '*', '?', character classes and backslash escapes
+func matchSegment(pattern, name string) bool {
+	matched, err := path.Match(convertClassNegation(pattern), name)
+	return err == nil && matched
+}
+
+// convertClassNegation rewrites "[!...]" character classes, which gitignore
+// uses for negation, to the "[^...]" form path.Match understands
+func convertClassNegation(pattern string) string {
+	if !strings.Contains(pattern, "[!") {
+		return pattern
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			builder.WriteString(pattern[i : i+2])
+			i++
+		case pattern[i] == '[' && i+1 < len(pattern) && pattern[i+1] == '!':
+			builder.WriteString("[^")
+			i++
+		default:
+			builder.WriteByte(pattern[i])
+		}
+	}
+	return builder.String()
+}
+
+// normalizeMatchPath turns a user supplied path into the slash separated,
+// relative form the rules are matched against
+func normalizeMatchPath(name string) string {
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
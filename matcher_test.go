@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+// TestMatcher tests path matching with git's semantics
+func TestMatcher(t *testing.T) {
+	content := "# Build output\n" +
+		"*.log\n" +
+		"!important.log\n" +
+		"build/\n" +
+		"/root.txt\n" +
+		"doc/*.txt\n" +
+		"**/cache\n" +
+		"a/**/z\n" +
+		"logs/**\n" +
+		"excluded/\n" +
+		"!excluded/keep.txt\n" +
+		"tmp[0-9]\n" +
+		"[!a]bc\n"
+	matcher := NewMatcher(ParseRules(content))
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"app.log", false, true},
+		{"deep/dir/app.log", false, true},
+		{"important.log", false, false},
+		{"deep/important.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build", true, true},
+		{"build/output.bin", false, true},
+		{"root.txt", false, true},
+		{"sub/root.txt", false, false},
+		{"doc/notes.txt", false, true},
+		{"doc/sub/notes.txt", false, false},
+		{"cache", true, true},
+		{"x/y/cache", false, true},
+		{"a/z", false, true},
+		{"a/b/c/z", false, true},
+		{"logs", true, false},
+		{"logs/today/app", false, true},
+		{"excluded/keep.txt", false, true},
+		{"tmp1", false, true},
+		{"tmpx", false, false},
+		{"xbc", false, true},
+		{"abc", false, false},
+		{"main.go", false, false},
+	}
+
+	for _, test := range tests {
+		if ignored := matcher.IsIgnored(test.path, test.isDir); ignored != test.ignored {
+			t.Errorf("%s (dir=%v): expected ignored=%v, got %v", test.path, test.isDir, test.ignored, ignored)
+		}
+	}
+}
+
+// TestMatchResult tests that the deciding rule is reported
+func TestMatchResult(t *testing.T) {
+	matcher := NewMatcher(ParseRules("*.log\n!keep.log\nvendor/\n"))
+
+	result := matcher.Match("keep.log", false)
+	if result.Ignored || !result.Matched || result.Rule.Line != 2 {
+		t.Errorf("Unexpected result for keep.log: %+v", result)
+	}
+
+	result = matcher.Match("vendor/pkg/keep.log", false)
+	if !result.Ignored || result.Parent != "vendor" || result.Rule.Line != 3 {
+		t.Errorf("Unexpected result for vendor/pkg/keep.log: %+v", result)
+	}
+
+	result = matcher.Match("main.go", false)
+	if result.Matched || result.Ignored {
+		t.Errorf("Unexpected result for main.go: %+v", result)
+	}
+}
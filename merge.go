@@ -0,0 +1,136 @@
+package main
+
+import "strings"
+
+// Conflict markers written into a block when local edits and the new
+// template change the same lines
+const (
+	conflictLocalMarker    = "<<<<<<< local"
+	conflictSeparator      = "======="
+	conflictUpstreamMarker = ">>>>>>> upstream"
+)
+
+// Merge3 performs a line based three-way merge of local and upstream, which
+// were both derived from base. Changes made on only one side are applied;
+// lines changed differently on both sides are wrapped in git-style conflict
+// markers. It reports whether there were any conflicts.
+func Merge3(base, local, upstream string) (string, bool) {
+	baseLines := splitLines(base)
+	localLines := splitLines(local)
+	upstreamLines := splitLines(upstream)
+
+	localMatches := matchLines(baseLines, localLines)
+	upstreamMatches := matchLines(baseLines, upstreamLines)
+
+	var merged []string
+	conflict := false
+	i, j, k := 0, 0, 0
+	for {
+		// Find the next base line kept unchanged on both sides
+		next := i
+		for next < len(baseLines) && (localMatches[next] < 0 || upstreamMatches[next] < 0) {
+			next++
+		}
+
+		localEnd, upstreamEnd := len(localLines), len(upstreamLines)
+		if next < len(baseLines) {
+			localEnd, upstreamEnd = localMatches[next], upstreamMatches[next]
+		}
+
+		// Resolve the chunk between the previous and the next stable line
+		chunk, ok := mergeChunk(baseLines[i:next], localLines[j:localEnd], upstreamLines[k:upstreamEnd])
+		merged = append(merged, chunk...)
+		if !ok {
+			conflict = true
+		}
+
+		if next == len(baseLines) {
+			break
+		}
+		merged = append(merged, baseLines[next])
+		i, j, k = next+1, localEnd+1, upstreamEnd+1
+	}
+
+	if len(merged) == 0 {
+		return "", conflict
+	}
+	return strings.Join(merged, "\n") + "\n", conflict
+}
+
+// mergeChunk merges one differing region. It returns false if both sides
+// changed the region in different ways.
+func mergeChunk(base, local, upstream []string) ([]string, bool) {
+	switch {
+	case equalLines(local, base):
+		return upstream, true
+	case equalLines(upstream, base), equalLines(local, upstream):
+		return local, true
+	}
+
+	chunk := []string{conflictLocalMarker}
+	chunk = append(chunk, local...)
+	chunk = append(chunk, conflictSeparator)
+	chunk = append(chunk, upstream...)
+	chunk = append(chunk, conflictUpstreamMarker)
+	return chunk, false
+}
+
+// matchLines computes the longest common subsequence of a and b and returns,
+// for every line of a, the index of the matching line in b or -1
+func matchLines(a, b []string) []int {
+	// lengths[i][j] is the LCS length of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+		case j < len(b) && lengths[i][j+1] > lengths[i+1][j]:
+			j++
+		default:
+			matches[i] = -1
+			i++
+		}
+	}
+	return matches
+}
+
+// splitLines splits text into lines without their line endings
+func splitLines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// equalLines reports whether two line slices are identical
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+// TestMerge3 tests the line based three-way merge
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		local    string
+		upstream string
+		expected string
+		conflict bool
+	}{
+		{
+			name:     "upstream change only",
+			base:     "a\nb\nc\n",
+			local:    "a\nb\nc\n",
+			upstream: "a\nb\nc\nd\n",
+			expected: "a\nb\nc\nd\n",
+		},
+		{
+			name:     "local and upstream change different lines",
+			base:     "a\nb\nc\n",
+			local:    "a\nb2\nc\n",
+			upstream: "a\nb\nc\nd\n",
+			expected: "a\nb2\nc\nd\n",
+		},
+		{
+			name:     "local deletion kept",
+			base:     "a\nb\nc\n",
+			local:    "a\nc\n",
+			upstream: "new\na\nb\nc\n",
+			expected: "new\na\nc\n",
+		},
+		{
+			name:     "same change on both sides",
+			base:     "a\nb\n",
+			local:    "a\nx\n",
+			upstream: "a\nx\n",
+			expected: "a\nx\n",
+		},
+		{
+			name:     "conflicting change",
+			base:     "a\nb\nc\n",
+			local:    "a\nlocal\nc\n",
+			upstream: "a\nupstream\nc\n",
+			expected: "a\n<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\nc\n",
+			conflict: true,
+		},
+	}
+
+	for _, test := range tests {
+		merged, conflict := Merge3(test.base, test.local, test.upstream)
+		if merged != test.expected {
+			t.Errorf("%s: expected '%s', got '%s'", test.name, test.expected, merged)
+		}
+		if conflict != test.conflict {
+			t.Errorf("%s: expected conflict %v, got %v", test.name, test.conflict, conflict)
+		}
+	}
+}
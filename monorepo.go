@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// monorepoSkipDirs are directories never searched for subprojects, even when
+// the root .gitignore does not exclude them
+var monorepoSkipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// Subproject is a directory inside a monorepo that needs its own templates
+type Subproject struct {
+	// Dir is the slash separated path relative to the monorepo root
+	Dir        string
+	Detections []Detection
+	// Covered lists detected templates that already have a block in the
+	// root .gitignore, either for the whole tree or rebased under Dir
+	Covered []string
+}
+
+// FindSubprojects walks the tree below root and runs project detection in
+// every directory. Directories excluded by the root .gitignore, hidden
+// directories and dependency folders are not searched. The root directory
+// itself is not reported.
+func FindSubprojects(root string, templates *Templates, config DetectionConfig, rootGitignore string) ([]Subproject, error) {
+	matcher := NewMatcher(ParseRules(rootGitignore))
+	rootFile, err := ParseGitignoreFile(rootGitignore)
+	if err != nil {
+		return nil, err
+	}
+
+	var subprojects []Subproject
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(info.Name(), ".") || monorepoSkipDirs[info.Name()] || matcher.IsIgnored(rel, true) {
+			return filepath.SkipDir
+		}
+
+		detections, err := DetectProjectTypes(path, templates, config)
+		if err != nil {
+			return err
+		}
+		if len(detections) == 0 {
+			return nil
+		}
+
+		// Templates with a block in the root .gitignore, either for the whole
+		// tree or rebased under this directory, are already covered
+		subproject := Subproject{Dir: rel}
+		for _, detection := range detections {
+			_, covered := rootFile.Block(detection.Template, "")
+			if !covered {
+				_, covered = rootFile.Block(detection.Template, rel+"/")
+			}
+			if covered {
+				subproject.Covered = append(subproject.Covered, detection.Template)
+				continue
+			}
+			subproject.Detections = append(subproject.Detections, detection)
+		}
+		subprojects = append(subprojects, subproject)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subprojects, nil
+}
+
+// withoutRootRules drops the template's patterns that the root .gitignore
+// already applies at every depth. Anchored root patterns such as "/build"
+// only match next to the root .gitignore, so they do not make a
+// subproject's "/build" redundant.
+func withoutRootRules(rootGitignore, template string) string {
+	applied := make(map[string]bool)
+	for _, rule := range ParseRules(rootGitignore).Patterns() {
+		if !rule.Anchored || (len(rule.Segments) > 1 && rule.Segments[0] == "**") {
+			applied[strings.TrimSpace(rule.Raw)] = true
+		}
+	}
+
+	var lines []string
+	for _, rule := range ParseRules(template).Rules {
+		if rule.Kind == RulePattern && applied[strings.TrimSpace(rule.Raw)] {
+			continue
+		}
+		lines = append(lines, rule.Raw)
+	}
+	return strings.Join(lines, "\n")
+}
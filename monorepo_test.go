@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFindSubprojects tests detecting subprojects in a monorepo
+func TestFindSubprojects(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-monorepo-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files := []string{
+		"go.mod",
+		"services/api/go.mod",
+		"web/package.json",
+		"web/node_modules/dep/package.json",
+		"infra/main.tf",
+		"build/generated/package.json",
+		".hidden/package.json",
+	}
+	for _, name := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		err = ioutil.WriteFile(path, []byte{}, 0644)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	rootGitignore := "/build/\n\n# >>> getignore: Go\n*.exe\n# <<< getignore: Go\n" +
+		"\n# >>> getignore: Node under=web/\n/web/**/node_modules/\n# <<< getignore: Node\n"
+	subprojects, err := FindSubprojects(tempDir, NewTemplates(), DetectionConfig{}, rootGitignore)
+	if err != nil {
+		t.Fatalf("FindSubprojects returned error: %v", err)
+	}
+
+	var found []string
+	for _, subproject := range subprojects {
+		var templates []string
+		for _, detection := range subproject.Detections {
+			templates = append(templates, detection.Template)
+		}
+		found = append(found, subproject.Dir+"="+strings.Join(templates, ",")+"/"+strings.Join(subproject.Covered, ","))
+	}
+
+	expected := []string{"infra=Terraform/", "services/api=/Go", "web=/Node"}
+	if strings.Join(found, " ") != strings.Join(expected, " ") {
+		t.Errorf("Unexpected subprojects.\nExpected: %v\nGot:      %v", expected, found)
+	}
+}
+
+// TestWithoutRootRules tests dropping rules the root .gitignore already applies
+func TestWithoutRootRules(t *testing.T) {
+	root := "node_modules/\n/dist\n**/coverage\n"
+	template := "# Dependencies\nnode_modules/\n/dist\n**/coverage\n*.log\n"
+
+	expected := "# Dependencies\n/dist\n*.log"
+	if result := withoutRootRules(root, template); result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import "strings"
+
+// RuleKind is the kind of a single gitignore line
+type RuleKind int
+
+const (
+	// RuleBlank is an empty or whitespace-only line
+	RuleBlank RuleKind = iota
+	// RuleComment is a line starting with '#'
+	RuleComment
+	// RulePattern is a line holding an ignore pattern
+	RulePattern
+)
+
+// Rule is a single parsed line of a gitignore file
+type Rule struct {
+	// Line is the 1-based line number
+	Line int
+	// Raw is the line exactly as written, without the trailing newline
+	Raw  string
+	Kind RuleKind
+
+	// The fields below are only set for RulePattern lines.
+
+	// Pattern is the glob with the leading '!' or '/', the trailing '/',
+	// the escape of a leading '#' or '!' and unescaped trailing spaces
+	// removed. Other backslash escapes are kept for matching.
+	Pattern string
+	// Segments is Pattern split on '/'; a "**" segment matches any number
+	// of directories
+	Segments []string
+	// Negate is set for patterns starting with '!', which re-include paths
+	Negate bool
+	// DirOnly is set for patterns ending in '/', which only match directories
+	DirOnly bool
+	// Anchored is set when the pattern contains a '/' before its end, so it
+	// only matches relative to the directory of the gitignore file
+	Anchored bool
+}
+
+// RuleList is a parsed gitignore file. String() gives back the original
+// text byte for byte.
+type RuleList struct {
+	Rules []Rule
+	// TrailingNewline records whether the last line ended with a newline
+	TrailingNewline bool
+}
+
+// ParseRules parses gitignore content into a list of rules
+func ParseRules(content string) *RuleList {
+	list := &RuleList{}
+	if content == "" {
+		return list
+	}
+
+	lines := strings.Split(content, "\n")
+	if lines[len(lines)-1] == "" {
+		list.TrailingNewline = true
+		lines = lines[:len(lines)-1]
+	}
+
+	for i, line := range lines {
+		list.Rules = append(list.Rules, ParseRule(line, i+1))
+	}
+
+	return list
+}
+
+// ParseRule parses a single gitignore line
+func ParseRule(raw string, lineNumber int) Rule {
+	rule := Rule{Line: lineNumber, Raw: raw}
+	line := strings.TrimSuffix(raw, "\r")
+
+	if strings.HasPrefix(line, "#") {
+		rule.Kind = RuleComment
+		return rule
+	}
+
+	line = trimTrailingSpaces(line)
+	if line == "" {
+		rule.Kind = RuleBlank
+		return rule
+	}
+	rule.Kind = RulePattern
+
+	// A leading '!' negates the pattern; "\!" and "\#" are literal
+	switch {
+	case strings.HasPrefix(line, "!"):
+		rule.Negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+
+	// A trailing '/' only matches directories
+	if strings.HasSuffix(line, "/") {
+		rule.DirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// A '/' at the beginning or in the middle anchors the pattern
+	if strings.Contains(line, "/") {
+		rule.Anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	rule.Pattern = line
+	if line != "" {
+		rule.Segments = strings.Split(line, "/")
+	}
+
+	return rule
+}
+
+// trimTrailingSpaces removes trailing spaces unless they are escaped with a
+// backslash
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		// Count the backslashes in front of this space
+		backslashes := 0
+		for i := end - 2; i >= 0 && line[i] == '\\'; i-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 {
+			break
+		}
+		end--
+	}
+	return line[:end]
+}
+
+// String renders the rule list back to text
+func (l *RuleList) String() string {
+	var builder strings.Builder
+	for i, rule := range l.Rules {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(rule.Raw)
+	}
+	if l.TrailingNewline {
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// Patterns returns only the pattern rules
+func (l *RuleList) Patterns() []Rule {
+	var patterns []Rule
+	for _, rule := range l.Rules {
+		if rule.Kind == RulePattern {
+			patterns = append(patterns, rule)
+		}
+	}
+	return patterns
+}
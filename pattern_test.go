@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+// TestParseRule tests parsing single gitignore lines
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		raw      string
+		kind     RuleKind
+		pattern  string
+		negate   bool
+		dirOnly  bool
+		anchored bool
+	}{
+		{"", RuleBlank, "", false, false, false},
+		{"   ", RuleBlank, "", false, false, false},
+		{"# comment", RuleComment, "", false, false, false},
+		{"*.log", RulePattern, "*.log", false, false, false},
+		{"!important.log", RulePattern, "important.log", true, false, false},
+		{"build/", RulePattern, "build", false, true, false},
+		{"/build", RulePattern, "build", false, false, true},
+		{"doc/*.txt", RulePattern, "doc/*.txt", false, false, true},
+		{"**/logs/", RulePattern, "**/logs", false, true, true},
+		{"a/**/b", RulePattern, "a/**/b", false, false, true},
+		{`\#file`, RulePattern, "#file", false, false, false},
+		{`\!file`, RulePattern, "!file", false, false, false},
+		{"trailing   ", RulePattern, "trailing", false, false, false},
+		{`space\ `, RulePattern, `space\ `, false, false, false},
+		{"crlf\r", RulePattern, "crlf", false, false, false},
+		{"!/bin/", RulePattern, "bin", true, true, true},
+	}
+
+	for _, test := range tests {
+		rule := ParseRule(test.raw, 1)
+		if rule.Kind != test.kind {
+			t.Errorf("%q: expected kind %v, got %v", test.raw, test.kind, rule.Kind)
+		}
+		if rule.Pattern != test.pattern {
+			t.Errorf("%q: expected pattern %q, got %q", test.raw, test.pattern, rule.Pattern)
+		}
+		if rule.Negate != test.negate || rule.DirOnly != test.dirOnly || rule.Anchored != test.anchored {
+			t.Errorf("%q: unexpected flags negate=%v dirOnly=%v anchored=%v", test.raw, rule.Negate, rule.DirOnly, rule.Anchored)
+		}
+	}
+
+	rule := ParseRule("a/**/b", 1)
+	if len(rule.Segments) != 3 || rule.Segments[1] != "**" {
+		t.Errorf("Unexpected segments %v", rule.Segments)
+	}
+}
+
+// TestParseRulesRoundTrip tests that parsed content renders back unchanged
+func TestParseRulesRoundTrip(t *testing.T) {
+	contents := []string{
+		"",
+		"*.log",
+		"# Logs\n*.log\n\n!keep.log\n",
+		"crlf\r\nlines\r\n",
+		"trailing  \nescaped\\ \n\n\n",
+	}
+
+	for _, content := range contents {
+		if rendered := ParseRules(content).String(); rendered != content {
+			t.Errorf("Round trip mismatch. Expected %q, got %q", content, rendered)
+		}
+	}
+
+	list := ParseRules("# Logs\n*.log\n\n/build/\n")
+	if len(list.Rules) != 4 {
+		t.Fatalf("Expected 4 rules, got %d", len(list.Rules))
+	}
+	patterns := list.Patterns()
+	if len(patterns) != 2 || patterns[1].Line != 4 || patterns[1].Pattern != "build" {
+		t.Errorf("Unexpected patterns %+v", patterns)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// projectConfigFileName is the name of the per-project config file, read
+// from the current directory
+const projectConfigFileName = ".getignore.json"
+
+// presetBlockPrefix starts the name of the managed block holding a preset's
+// extra lines, e.g. "preset:backend"
+const presetBlockPrefix = "preset:"
+
+// presetNamePattern limits preset names to what fits in a block header and
+// on the command line
+var presetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// reservedPresetNames are the preset subcommands
+var reservedPresetNames = map[string]bool{
+	"list": true,
+	"show": true,
+	"save": true,
+}
+
+// Preset bundles several templates and extra lines under one name
+type Preset struct {
+	Templates []string `json:"templates"`
+	// Extra lines are written to their own block after the templates
+	Extra []string `json:"extra,omitempty"`
+}
+
+// NamedPreset is a preset with its name and the file defining it
+type NamedPreset struct {
+	Preset
+	Name   string
+	Source string
+}
+
+// Presets holds the presets from the user config and the project file.
+// Project presets replace user presets with the same name.
+type Presets struct {
+	presets map[string]NamedPreset
+}
+
+// LoadPresets combines the presets of the user config with those of the
+// project file in projectDir
+func LoadPresets(config Config, projectDir string) (*Presets, error) {
+	presets := &Presets{presets: make(map[string]NamedPreset)}
+
+	userPath, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	presets.add(config.Presets, userPath)
+
+	projectPath := filepath.Join(projectDir, projectConfigFileName)
+	project, err := loadConfigFile(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	presets.add(project.Presets, projectPath)
+
+	return presets, nil
+}
+
+// add stores the presets defined in one file
+func (p *Presets) add(presets map[string]Preset, source string) {
+	for name, preset := range presets {
+		p.presets[name] = NamedPreset{Preset: preset, Name: name, Source: source}
+	}
+}
+
+// Get returns the preset with the given name, ignoring case if there is no
+// exact match
+func (p *Presets) Get(name string) (NamedPreset, bool) {
+	if preset, ok := p.presets[name]; ok {
+		return preset, true
+	}
+	for _, preset := range p.List() {
+		if strings.EqualFold(preset.Name, name) {
+			return preset, true
+		}
+	}
+	return NamedPreset{}, false
+}
+
+// List returns all presets sorted by name
+func (p *Presets) List() []NamedPreset {
+	var presets []NamedPreset
+	for _, preset := range p.presets {
+		presets = append(presets, preset)
+	}
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].Name < presets[j].Name
+	})
+	return presets
+}
+
+// ExtraSection returns the block holding the preset's extra lines, or nil
+// if it has none
+func (p NamedPreset) ExtraSection(under string) *TemplateSection {
+	if len(p.Extra) == 0 {
+		return nil
+	}
+	return &TemplateSection{
+		Name:    presetBlockPrefix + p.Name,
+		Content: strings.Join(p.Extra, "\n"),
+		Under:   under,
+	}
+}
+
+// SavePreset adds or replaces a preset in the config file at path
+func SavePreset(path, name string, preset Preset) error {
+	if !presetNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid preset name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	if reservedPresetNames[strings.ToLower(name)] {
+		return fmt.Errorf("'%s' is reserved and cannot be used as a preset name", name)
+	}
+	if len(preset.Templates) == 0 {
+		return fmt.Errorf("a preset needs at least one template")
+	}
+
+	config, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	if config.Presets == nil {
+		config.Presets = make(map[string]Preset)
+	}
+	config.Presets[name] = preset
+
+	return saveConfigFile(path, config)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPresets tests loading presets from the user config and the project file
+func TestPresets(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
+
+	userPath, err := configPath()
+	if err != nil {
+		t.Fatalf("configPath failed: %v", err)
+	}
+	projectPath := filepath.Join(tempDir, projectConfigFileName)
+
+	err = SavePreset(userPath, "backend", Preset{Templates: []string{"Go", "Global/JetBrains"}, Extra: []string{"*.env"}})
+	if err != nil {
+		t.Fatalf("SavePreset failed: %v", err)
+	}
+	err = SavePreset(userPath, "web", Preset{Templates: []string{"Node"}})
+	if err != nil {
+		t.Fatalf("SavePreset failed: %v", err)
+	}
+	err = SavePreset(projectPath, "web", Preset{Templates: []string{"Node", "Global/macOS"}})
+	if err != nil {
+		t.Fatalf("SavePreset failed: %v", err)
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	presets, err := LoadPresets(config, tempDir)
+	if err != nil {
+		t.Fatalf("LoadPresets failed: %v", err)
+	}
+
+	var names []string
+	for _, preset := range presets.List() {
+		names = append(names, preset.Name)
+	}
+	if strings.Join(names, ",") != "backend,web" {
+		t.Errorf("Expected presets backend,web, got %v", names)
+	}
+
+	// The project file wins over the user config
+	web, ok := presets.Get("web")
+	if !ok || web.Source != projectPath || len(web.Templates) != 2 {
+		t.Errorf("Expected the project preset for web, got %+v", web)
+	}
+
+	backend, ok := presets.Get("Backend")
+	if !ok {
+		t.Fatalf("Expected a case-insensitive lookup to find backend")
+	}
+	section := backend.ExtraSection("api")
+	if section == nil || section.Name != "preset:backend" || section.Content != "*.env" || section.Under != "api" {
+		t.Errorf("Unexpected extra section: %+v", section)
+	}
+	if web.ExtraSection("") != nil {
+		t.Errorf("Expected no extra section for a preset without extra lines")
+	}
+}
+
+// TestSavePresetValidation tests that invalid presets are rejected
+func TestSavePresetValidation(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, projectConfigFileName)
+
+	tests := []struct {
+		name   string
+		preset Preset
+	}{
+		{"list", Preset{Templates: []string{"Go"}}},
+		{"my preset", Preset{Templates: []string{"Go"}}},
+		{"empty", Preset{}},
+	}
+
+	for _, test := range tests {
+		if err := SavePreset(path, test.name, test.preset); err == nil {
+			t.Errorf("Expected SavePreset(%q) to fail", test.name)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be written for invalid presets")
+	}
+}
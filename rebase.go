@@ -0,0 +1,72 @@
+package main
+
+import "strings"
+
+// normalizeUnder cleans a --under directory into the "dir/sub/" form used in
+// block headers, or "" for the repository root
+func normalizeUnder(under string) string {
+	under = strings.ReplaceAll(under, "\\", "/")
+	under = normalizeMatchPath(under)
+	if under == "" {
+		return ""
+	}
+	return under + "/"
+}
+
+// RebaseRules rewrites every pattern of a template so that it only applies
+// inside the given directory, for use in a single root .gitignore:
+//   - anchored patterns ("/dist", "doc/*.txt", "**/logs") are prefixed
+//     with the directory
+//   - unanchored patterns ("*.log", "node_modules/") match at any depth,
+//     so they become "dir/**/pattern"
+//   - negations and trailing slashes are kept
+//
+// Comments and blank lines are left untouched.
+func RebaseRules(content, under string) string {
+	under = normalizeUnder(under)
+	if under == "" {
+		return content
+	}
+	prefix := escapeGlob(under)
+
+	list := ParseRules(content)
+	for i, rule := range list.Rules {
+		if rule.Kind != RulePattern || rule.Pattern == "" {
+			continue
+		}
+
+		var builder strings.Builder
+		if rule.Negate {
+			builder.WriteString("!")
+		}
+		builder.WriteString("/" + prefix)
+		if !rule.Anchored {
+			builder.WriteString("**/")
+		}
+		builder.WriteString(rule.Pattern)
+		if rule.DirOnly {
+			builder.WriteString("/")
+		}
+		if strings.HasSuffix(rule.Raw, "\r") {
+			builder.WriteString("\r")
+		}
+
+		list.Rules[i] = ParseRule(builder.String(), rule.Line)
+	}
+
+	return list.String()
+}
+
+// escapeGlob escapes the characters that have a special meaning in
+// gitignore patterns, so a directory name is matched literally
+func escapeGlob(name string) string {
+	var builder strings.Builder
+	for _, r := range name {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
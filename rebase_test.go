@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+// TestRebaseRules tests rewriting template rules under a subdirectory
+func TestRebaseRules(t *testing.T) {
+	template := "# Dependencies\n" +
+		"node_modules/\n" +
+		"*.log\n" +
+		"!keep.log\n" +
+		"/dist\n" +
+		"docs/*.html\n" +
+		"**/coverage\n" +
+		"\\#notes\n" +
+		"\n"
+
+	expected := "# Dependencies\n" +
+		"/web/**/node_modules/\n" +
+		"/web/**/*.log\n" +
+		"!/web/**/keep.log\n" +
+		"/web/dist\n" +
+		"/web/docs/*.html\n" +
+		"/web/**/coverage\n" +
+		"/web/**/#notes\n" +
+		"\n"
+
+	for _, under := range []string{"web/", "web", "/web/", "./web"} {
+		if rebased := RebaseRules(template, under); rebased != expected {
+			t.Errorf("RebaseRules(%q) mismatch.\nExpected: %q\nGot:      %q", under, expected, rebased)
+		}
+	}
+
+	if rebased := RebaseRules(template, ""); rebased != template {
+		t.Errorf("RebaseRules without a directory should not change the template, got %q", rebased)
+	}
+}
+
+// TestRebaseRulesMatching tests that rebased rules only match inside the directory
+func TestRebaseRulesMatching(t *testing.T) {
+	template := "node_modules/\n*.log\n!keep.log\n/dist\n"
+	matcher := NewMatcher(ParseRules(RebaseRules(template, "apps/web")))
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"apps/web/node_modules", true, true},
+		{"apps/web/src/node_modules", true, true},
+		{"node_modules", true, false},
+		{"apps/web/debug.log", false, true},
+		{"apps/web/keep.log", false, false},
+		{"debug.log", false, false},
+		{"apps/web/dist", false, true},
+		{"apps/web/src/dist", false, false},
+		{"dist", false, false},
+	}
+
+	for _, test := range tests {
+		if ignored := matcher.IsIgnored(test.path, test.isDir); ignored != test.ignored {
+			t.Errorf("%s: expected ignored=%v, got %v", test.path, test.ignored, ignored)
+		}
+	}
+}
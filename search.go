@@ -0,0 +1,159 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Match quality of a search result, best first
+const (
+	searchExact = iota
+	searchPrefix
+	searchSubstring
+	searchPath
+	searchFuzzy
+)
+
+// searchResult is a template name with its match quality
+type searchResult struct {
+	name     string
+	quality  int
+	distance int
+}
+
+// Search ranks the local templates against a search term. Names are
+// compared case-insensitively, mostly by their last path component, so
+// "jetbrain" finds "Global/JetBrains". The term is also matched against the
+// aliases, so "idea" finds "Global/JetBrains" too. The best matches come
+// first.
+func (t *Templates) Search(term string) []string {
+	term = strings.ToLower(strings.TrimSpace(term))
+	if term == "" {
+		return nil
+	}
+
+	// Keep the best match for every template
+	best := make(map[string]searchResult)
+	add := func(result searchResult) {
+		if current, ok := best[result.name]; !ok || betterResult(result, current) {
+			best[result.name] = result
+		}
+	}
+
+	for _, name := range t.ListTemplates() {
+		if quality, distance, ok := rankTemplate(name, term); ok {
+			add(searchResult{name: name, quality: quality, distance: distance})
+		}
+	}
+	for alias, target := range t.Aliases {
+		quality, distance, ok := rankTemplate(alias, term)
+		if !ok {
+			continue
+		}
+		if name, err := t.resolveName(target); err == nil {
+			add(searchResult{name: name, quality: quality, distance: distance})
+		}
+	}
+
+	var results []searchResult
+	for _, result := range best {
+		results = append(results, result)
+	}
+	return sortResults(results)
+}
+
+// betterResult reports whether a ranks before b
+func betterResult(a, b searchResult) bool {
+	if a.quality != b.quality {
+		return a.quality < b.quality
+	}
+	if a.distance != b.distance {
+		return a.distance < b.distance
+	}
+	return a.name < b.name
+}
+
+// sortResults orders search results best first and returns their names
+func sortResults(results []searchResult) []string {
+	sort.Slice(results, func(i, j int) bool {
+		return betterResult(results[i], results[j])
+	})
+
+	matches := make([]string, len(results))
+	for i, result := range results {
+		matches[i] = result.name
+	}
+	return matches
+}
+
+// Suggest returns up to limit template names close to the term, for "did
+// you mean" hints
+func (t *Templates) Suggest(term string, limit int) []string {
+	matches := t.Search(term)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// rankTemplate scores how well a template name matches the lowercase term
+func rankTemplate(name, term string) (int, int, bool) {
+	lowerName := strings.ToLower(name)
+	base := path.Base(lowerName)
+	distance := editDistance(base, term)
+
+	switch {
+	case lowerName == term || base == term:
+		return searchExact, distance, true
+	case strings.HasPrefix(base, term) || (len(base) >= 2 && strings.HasPrefix(term, base)):
+		return searchPrefix, distance, true
+	case strings.Contains(base, term) || (len(base) >= 2 && strings.Contains(term, base)):
+		return searchSubstring, distance, true
+	case strings.Contains(lowerName, term):
+		return searchPath, distance, true
+	}
+
+	// Allow roughly one typo per three characters
+	maxDistance := len(term) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	if distance <= maxDistance {
+		return searchFuzzy, distance, true
+	}
+
+	return 0, 0, false
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	previous := make([]int, len(br)+1)
+	current := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		current[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(br)]
+}
+
+// minInt returns the smaller of two ints
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSearch tests ranking the template names against a search term
+func TestSearch(t *testing.T) {
+	templates := NewTemplates()
+	for _, name := range []string{
+		"Go",
+		"Godot",
+		"Node",
+		"Python",
+		"Global/JetBrains",
+		"Global/macOS",
+		"community/JavaScript/Vue",
+		"community/Golang/Hugo",
+	} {
+		templates.templates[name] = "# " + name
+	}
+
+	tests := []struct {
+		term     string
+		expected []string
+	}{
+		{"go", []string{"Go", "Godot", "community/Golang/Hugo"}},
+		{"golang", []string{"Go", "community/Golang/Hugo"}},
+		{"jetbrain", []string{"Global/JetBrains"}},
+		{"pyhton", []string{"Python"}},
+		{"MACOS", []string{"Global/macOS"}},
+		{"vue", []string{"community/JavaScript/Vue"}},
+		{"nothing-like-it", nil},
+	}
+
+	for _, test := range tests {
+		results := templates.Search(test.term)
+		if strings.Join(results, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("Search(%q) = %v, expected %v", test.term, results, test.expected)
+		}
+	}
+}
+
+// TestSearchAliases tests that searching also matches aliases
+func TestSearchAliases(t *testing.T) {
+	templates := NewTemplates()
+	for _, name := range []string{"Go", "Godot", "Node", "Global/JetBrains", "Global/macOS"} {
+		templates.templates[name] = "# " + name
+	}
+	templates.Aliases = Config{}.AllAliases()
+
+	tests := []struct {
+		term     string
+		expected []string
+	}{
+		{"golang", []string{"Go"}},
+		{"idea", []string{"Global/JetBrains"}},
+		{"js", []string{"Node"}},
+		{"mac", []string{"Global/macOS"}},
+		// Aliases for templates that are not available are left out
+		{"rust", nil},
+	}
+
+	for _, test := range tests {
+		results := templates.Search(test.term)
+		if strings.Join(results, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("Search(%q) = %v, expected %v", test.term, results, test.expected)
+		}
+	}
+
+	if suggestions := templates.Suggest("go", 2); len(suggestions) != 2 {
+		t.Errorf("Expected 2 suggestions, got %v", suggestions)
+	}
+}
+
+// TestEditDistance tests the Levenshtein distance
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"go", "", 2},
+		{"python", "pyhton", 2},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, test := range tests {
+		if distance := editDistance(test.a, test.b); distance != test.distance {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", test.a, test.b, distance, test.distance)
+		}
+	}
+}
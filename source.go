@@ -0,0 +1,503 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errTemplateNotFound is returned by TemplateSource.Fetch for paths the
+// source does not have
+var errTemplateNotFound = errors.New("template not found")
+
+// TemplateSource is a place templates come from, such as a GitHub
+// repository or a local directory
+type TemplateSource interface {
+	// Name describes the source for messages
+	Name() string
+	// List returns the paths of all templates, relative to the root of the
+	// source and separated by "/", e.g. "Global/macOS.gitignore"
+	List() ([]string, error)
+	// Fetch returns the content of the template at path
+	Fetch(path string) ([]byte, error)
+	// Revision identifies the current version of the templates, or is empty
+	// if the source is not versioned
+	Revision() (string, error)
+}
+
+// archiver is implemented by sources that can provide all their templates
+// in one download, which is faster than fetching them one by one
+type archiver interface {
+	Archive() TemplateSource
+}
+
+// blobHasher is implemented by sources that know the git blob SHA of every
+// template, so downloads can skip the templates that are up to date
+type blobHasher interface {
+	Hashes() (map[string]string, error)
+}
+
+// gitBlobSHA returns the SHA git gives a file with this content
+func gitBlobSHA(content []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "blob %d\x00", len(content))
+	hash.Write(content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ParseSource creates the source described by spec:
+//
+//	github          the upstream repository on GitHub
+//	dir:PATH        a local directory
+//	git:PATH[@REF]  a local git checkout, at HEAD unless REF is given
+//	tarball:URL     a .tar.gz archive downloaded from URL
+func ParseSource(spec string, upstream Upstream, client *http.Client) (TemplateSource, error) {
+	kind, location, _ := strings.Cut(spec, ":")
+	switch {
+	case spec == "" || spec == "github":
+		return NewGitHubSource(upstream, client), nil
+	case location == "":
+		return nil, fmt.Errorf("invalid source '%s': expected github, dir:PATH, git:PATH[@REF] or tarball:URL", spec)
+	case kind == "dir":
+		return &DirSource{Dir: location}, nil
+	case kind == "git":
+		dir, ref, _ := strings.Cut(location, "@")
+		return &GitSource{Dir: dir, Ref: ref}, nil
+	case kind == "tarball":
+		return &TarballSource{URL: location, Client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown source type '%s': expected github, dir, git or tarball", kind)
+}
+
+// isTemplatePath reports whether a path names a template file. A file called
+// just ".gitignore" is the directory's own ignore file, not a template.
+func isTemplatePath(p string) bool {
+	return strings.HasSuffix(p, ".gitignore") && path.Base(p) != ".gitignore"
+}
+
+// cleanSourcePath checks that a template path stays inside the source and
+// returns it in clean form
+func cleanSourcePath(p string) (string, error) {
+	clean := path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))[1:]
+	if clean == "" || clean != strings.TrimPrefix(p, "./") || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid template path '%s'", p)
+	}
+	return clean, nil
+}
+
+// DirSource reads templates from a local directory laid out like the
+// github/gitignore repository. Directories starting with a dot are skipped.
+type DirSource struct {
+	Dir string
+}
+
+// Name returns the directory
+func (s *DirSource) Name() string {
+	return s.Dir
+}
+
+// List returns every template below the directory, in lexical order
+func (s *DirSource) List() ([]string, error) {
+	var paths []string
+	err := filepath.Walk(s.Dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if file != s.Dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.Dir, file)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); isTemplatePath(rel) {
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory %s: %v", s.Dir, err)
+	}
+	return paths, nil
+}
+
+// Fetch reads a template file
+func (s *DirSource) Fetch(p string) ([]byte, error) {
+	clean, err := cleanSourcePath(p)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(filepath.Join(s.Dir, filepath.FromSlash(clean)))
+	if os.IsNotExist(err) {
+		return nil, errTemplateNotFound
+	}
+	return content, err
+}
+
+// Revision is always empty, as a plain directory has no versions
+func (s *DirSource) Revision() (string, error) {
+	return "", nil
+}
+
+// GitSource reads templates from a commit of a local git repository, without
+// touching its working tree. All templates are read at once, so a whole
+// listing costs two git processes rather than one per template.
+type GitSource struct {
+	Dir string
+	// Ref is the branch, tag or commit to read; empty means HEAD
+	Ref string
+
+	// hashes and files hold the blob SHA and content of every template once
+	// they have been read
+	hashes map[string]string
+	files  map[string][]byte
+}
+
+// Name returns the repository and the ref
+func (s *GitSource) Name() string {
+	return s.Dir + "@" + s.ref()
+}
+
+// ref returns the ref to read, defaulting to HEAD
+func (s *GitSource) ref() string {
+	if s.Ref == "" {
+		return "HEAD"
+	}
+	return s.Ref
+}
+
+// git runs a git command in the repository and returns its output
+func (s *GitSource) git(args ...string) ([]byte, error) {
+	return s.gitInput(nil, args...)
+}
+
+// gitInput runs a git command in the repository with input on its stdin and
+// returns its output
+func (s *GitSource) gitInput(input []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", s.Dir}, args...)...)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], message)
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return output, nil
+}
+
+// List returns every template in the commit
+func (s *GitSource) List() ([]string, error) {
+	hashes, err := s.Hashes()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for p := range hashes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Hashes returns the blob SHA of every template in the commit
+func (s *GitSource) Hashes() (map[string]string, error) {
+	if s.hashes != nil {
+		return s.hashes, nil
+	}
+
+	output, err := s.git("ls-tree", "-r", "-z", s.ref())
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries look like "<mode> blob <sha>\t<path>"
+	hashes := make(map[string]string)
+	for _, entry := range strings.Split(string(output), "\x00") {
+		info, p, found := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !found || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		if isTemplatePath(p) && !strings.HasPrefix(p, ".") && !strings.Contains(p, "/.") {
+			hashes[p] = fields[2]
+		}
+	}
+	s.hashes = hashes
+	return hashes, nil
+}
+
+// load reads the content of every template with a single git cat-file, if
+// that has not happened yet
+func (s *GitSource) load() error {
+	if s.files != nil {
+		return nil
+	}
+	hashes, err := s.Hashes()
+	if err != nil {
+		return err
+	}
+
+	var input bytes.Buffer
+	for _, hash := range hashes {
+		fmt.Fprintln(&input, hash)
+	}
+	output, err := s.gitInput(input.Bytes(), "cat-file", "--batch")
+	if err != nil {
+		return err
+	}
+
+	// Every object is "<sha> blob <size>\n<content>\n", in input order
+	contents := make(map[string][]byte)
+	for len(output) > 0 {
+		header, rest, _ := bytes.Cut(output, []byte("\n"))
+		fields := strings.Fields(string(header))
+		var size int
+		if len(fields) == 3 {
+			size, err = strconv.Atoi(fields[2])
+		}
+		if len(fields) != 3 || err != nil || size+1 > len(rest) {
+			return fmt.Errorf("git cat-file: unexpected output '%s'", header)
+		}
+		contents[fields[0]] = rest[:size]
+		output = rest[size+1:]
+	}
+
+	files := make(map[string][]byte)
+	for p, hash := range hashes {
+		content, ok := contents[hash]
+		if !ok {
+			return fmt.Errorf("git cat-file: missing blob %s for %s", hash, p)
+		}
+		files[p] = content
+	}
+	s.files = files
+	return nil
+}
+
+// Fetch returns a template from the commit
+func (s *GitSource) Fetch(p string) ([]byte, error) {
+	clean, err := cleanSourcePath(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	content, ok := s.files[clean]
+	if !ok {
+		return nil, errTemplateNotFound
+	}
+	return content, nil
+}
+
+// Revision returns the SHA of the commit
+func (s *GitSource) Revision() (string, error) {
+	output, err := s.git("rev-parse", s.ref()+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// TarballSource reads templates from a gzipped tar archive at a URL. The
+// archive is downloaded once; a single top-level directory, as in archives
+// made by GitHub, is left out of the template paths.
+type TarballSource struct {
+	URL    string
+	Client *http.Client
+	// Accept limits the templates taken from the archive; nil takes all
+	Accept func(path string) bool
+
+	files    map[string][]byte
+	revision string
+	// loadErr is the error of the first download, so a failing archive is
+	// not downloaded again for every template
+	loadErr error
+}
+
+// Name returns the URL of the archive
+func (s *TarballSource) Name() string {
+	return s.URL
+}
+
+// load downloads and unpacks the archive if that has not been tried yet
+func (s *TarballSource) load() error {
+	if s.files == nil && s.loadErr == nil {
+		s.loadErr = s.download()
+	}
+	return s.loadErr
+}
+
+// download downloads and unpacks the archive
+func (s *TarballSource) download() error {
+	resp, err := s.Client.Get(s.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s, status code: %d", s.URL, resp.StatusCode)
+	}
+	archive, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", s.URL, err)
+	}
+
+	files, err := readTarball(archive, s.Accept)
+	if err != nil {
+		return fmt.Errorf("error unpacking %s: %v", s.URL, err)
+	}
+	sum := sha256.Sum256(archive)
+	s.files = files
+	s.revision = hex.EncodeToString(sum[:])[:12]
+	return nil
+}
+
+// readTarball returns the templates in a gzipped tar archive keyed by path,
+// leaving out those accept rejects if it is not nil. Archives with entries
+// leaving the archive are rejected.
+func readTarball(archive []byte, accept func(path string) bool) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var names []string
+	files := make(map[string][]byte)
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeDir {
+			continue
+		}
+
+		// The archive's own root, e.g. "./", is no entry of its own
+		trimmed := strings.TrimSuffix(header.Name, "/")
+		if path.Clean("/"+trimmed) == "/" {
+			continue
+		}
+		name, err := cleanSourcePath(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if header.Typeflag != tar.TypeReg || !isTemplatePath(name) {
+			continue
+		}
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+
+	// Leave out a top-level directory holding everything else
+	top := topDir(names)
+	templates := make(map[string][]byte)
+	for name, content := range files {
+		name = strings.TrimPrefix(name, top)
+		if accept == nil || accept(name) {
+			templates[name] = content
+		}
+	}
+	return templates, nil
+}
+
+// templateDirs are the directories of github/gitignore that hold templates
+var templateDirs = map[string]bool{
+	"Global":    true,
+	"community": true,
+}
+
+// topDir returns the directory, with a trailing slash, that all paths are
+// in, or "" if they do not share one or it is one of the template
+// directories
+func topDir(paths []string) string {
+	top := ""
+	for _, p := range paths {
+		dir, _, _ := strings.Cut(p, "/")
+		if top != "" && dir != top {
+			return ""
+		}
+		top = dir
+	}
+	if templateDirs[top] {
+		return ""
+	}
+
+	// A single file is not a directory
+	for _, p := range paths {
+		if strings.HasPrefix(p, top+"/") {
+			return top + "/"
+		}
+	}
+	return ""
+}
+
+// List returns every template in the archive, in lexical order
+func (s *TarballSource) List() ([]string, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for p := range s.files {
+		if !strings.HasPrefix(p, ".") && !strings.Contains(p, "/.") {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Fetch returns a template from the archive
+func (s *TarballSource) Fetch(p string) ([]byte, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	content, ok := s.files[p]
+	if !ok {
+		return nil, errTemplateNotFound
+	}
+	return content, nil
+}
+
+// Revision returns a checksum of the archive
+func (s *TarballSource) Revision() (string, error) {
+	if err := s.load(); err != nil {
+		return "", err
+	}
+	return s.revision, nil
+}
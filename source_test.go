@@ -0,0 +1,300 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files below dir from a map of slash separated paths
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+// checkSource tests that a source lists and fetches the expected templates
+func checkSource(t *testing.T, source TemplateSource, expected map[string]string) {
+	paths, err := source.List()
+	if err != nil {
+		t.Fatalf("%s: List returned error: %v", source.Name(), err)
+	}
+
+	var expectedPaths []string
+	for path := range expected {
+		expectedPaths = append(expectedPaths, path)
+	}
+	sort.Strings(expectedPaths)
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("%s: List() = %v, expected %v", source.Name(), paths, expectedPaths)
+	}
+
+	for path, content := range expected {
+		fetched, err := source.Fetch(path)
+		if err != nil {
+			t.Errorf("%s: Fetch(%q) returned error: %v", source.Name(), path, err)
+		} else if string(fetched) != content {
+			t.Errorf("%s: Fetch(%q) = %q, expected %q", source.Name(), path, fetched, content)
+		}
+	}
+
+	if _, err := source.Fetch("Missing.gitignore"); err != errTemplateNotFound {
+		t.Errorf("%s: expected errTemplateNotFound for a missing template, got %v", source.Name(), err)
+	}
+	if _, err := source.Fetch("../outside.gitignore"); err == nil {
+		t.Errorf("%s: expected an error for a path outside the source", source.Name())
+	}
+}
+
+// sourceFiles are the files of the test sources. Only those also in
+// sourceTemplates are templates.
+var sourceFiles = map[string]string{
+	"Go.gitignore":                        "*.exe\n",
+	"Global/macOS.gitignore":              ".DS_Store\n",
+	"community/JavaScript/Vue.gitignore":  "dist/\n",
+	"README.md":                           "# Templates\n",
+	".gitignore":                          "*.tmp\n",
+	".snapshots/Ignored.gitignore":        "ignored\n",
+	"community/JavaScript/notes.txt.orig": "notes\n",
+}
+
+// sourceTemplates are the templates among sourceFiles
+var sourceTemplates = map[string]string{
+	"Go.gitignore":                       "*.exe\n",
+	"Global/macOS.gitignore":             ".DS_Store\n",
+	"community/JavaScript/Vue.gitignore": "dist/\n",
+}
+
+// TestDirSource tests reading templates from a local directory
+func TestDirSource(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-source-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	writeFiles(t, tempDir, sourceFiles)
+
+	checkSource(t, &DirSource{Dir: tempDir}, sourceTemplates)
+}
+
+// TestGitSource tests reading templates from a commit of a git repository
+func TestGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tempDir, err := ioutil.TempDir("", "gitignore-source-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	writeFiles(t, tempDir, sourceFiles)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", tempDir, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "Add templates")
+	git("tag", "v1")
+
+	// Changes after the tag are not seen at the tag
+	writeFiles(t, tempDir, map[string]string{"Go.gitignore": "*.test\n"})
+	git("commit", "-q", "-a", "-m", "Change Go")
+
+	source := &GitSource{Dir: tempDir, Ref: "v1"}
+	checkSource(t, source, sourceTemplates)
+
+	hashes, err := source.Hashes()
+	if err != nil || len(hashes) != len(sourceTemplates) || hashes["Go.gitignore"] != gitBlobSHA([]byte("*.exe\n")) {
+		t.Errorf("Unexpected blob SHAs %v (%v)", hashes, err)
+	}
+
+	revision, err := source.Revision()
+	if err != nil || len(revision) != 40 {
+		t.Errorf("Expected a commit SHA as revision, got %q (%v)", revision, err)
+	}
+
+	content, err := (&GitSource{Dir: tempDir}).Fetch("Go.gitignore")
+	if err != nil || string(content) != "*.test\n" {
+		t.Errorf("Expected HEAD to have the changed template, got %q (%v)", content, err)
+	}
+
+	// The templates were all read at once, so git is not needed again
+	if err := os.Rename(tempDir, tempDir+".moved"); err != nil {
+		t.Fatalf("Failed to move the repository: %v", err)
+	}
+	defer os.RemoveAll(tempDir + ".moved")
+	content, err = source.Fetch("Global/macOS.gitignore")
+	if err != nil || string(content) != ".DS_Store\n" {
+		t.Errorf("Expected the template to be read already, got %q (%v)", content, err)
+	}
+}
+
+// makeTarball builds a gzipped tar archive from a map of paths to contents
+func makeTarball(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(gz)
+	for name, content := range files {
+		err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		archive.Write([]byte(content))
+	}
+	archive.Close()
+	gz.Close()
+	return buffer.Bytes()
+}
+
+// makeTarballEntries builds a gzipped tar archive with the entries in order.
+// Entries ending in "/" are directories; files contain their own name.
+func makeTarballEntries(t *testing.T, entries []string) []byte {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(gz)
+	for _, name := range entries {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(name)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(name, "/") {
+			header = &tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		err := archive.WriteHeader(header)
+		if err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			archive.Write([]byte(name))
+		}
+	}
+	archive.Close()
+	gz.Close()
+	return buffer.Bytes()
+}
+
+// TestTarballSource tests reading templates from an archive at a URL
+func TestTarballSource(t *testing.T) {
+	// Archives made by GitHub have a single top-level directory
+	files := make(map[string]string)
+	for name, content := range sourceFiles {
+		files["gitignore-main/"+name] = content
+	}
+	archive := makeTarball(t, files)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/missing.tar.gz" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	source := &TarballSource{URL: server.URL + "/templates.tar.gz", Client: server.Client()}
+	checkSource(t, source, sourceTemplates)
+
+	if revision, err := source.Revision(); err != nil || revision == "" {
+		t.Errorf("Expected a revision, got %q (%v)", revision, err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected the archive to be downloaded once, got %d requests", requests)
+	}
+
+	// A failed download is not repeated
+	failing := &TarballSource{URL: server.URL + "/missing.tar.gz", Client: server.Client()}
+	requests = 0
+	for i := 0; i < 3; i++ {
+		if _, err := failing.Fetch("Go.gitignore"); err == nil || err == errTemplateNotFound {
+			t.Errorf("Expected the download error, got %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected a failed archive to be requested once, got %d requests", requests)
+	}
+
+	// A directory is only left out when it holds everything and is not a
+	// template directory
+	layouts := []struct {
+		entries  []string
+		expected []string
+	}{
+		{[]string{"Global/macOS.gitignore", "README.md"}, []string{"Global/macOS.gitignore"}},
+		{[]string{"Global/", "Global/macOS.gitignore"}, []string{"Global/macOS.gitignore"}},
+		{[]string{"community/Go/Hugo.gitignore"}, []string{"community/Go/Hugo.gitignore"}},
+		{[]string{"templates/", "templates/Go.gitignore"}, []string{"Go.gitignore"}},
+		// As made by "tar czf templates.tgz -C dir ."
+		{[]string{"./", "./Go.gitignore", "./Global/", "./Global/macOS.gitignore"}, []string{"Global/macOS.gitignore", "Go.gitignore"}},
+	}
+	for _, layout := range layouts {
+		templates, err := readTarball(makeTarballEntries(t, layout.entries), nil)
+		if err != nil {
+			t.Errorf("readTarball(%v) returned error: %v", layout.entries, err)
+			continue
+		}
+		var names []string
+		for name := range templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, layout.expected) {
+			t.Errorf("readTarball(%v) = %v, expected %v", layout.entries, names, layout.expected)
+		}
+	}
+
+	// Entries leaving the archive are rejected
+	if _, err := readTarball(makeTarball(t, map[string]string{"../evil.gitignore": "x\n"}), nil); err == nil {
+		t.Error("Expected an error for an entry outside the archive")
+	}
+}
+
+// TestParseSource tests creating sources from their description
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected TemplateSource
+	}{
+		{"", NewGitHubSource(defaultUpstream, http.DefaultClient)},
+		{"github", NewGitHubSource(defaultUpstream, http.DefaultClient)},
+		{"dir:/srv/templates", &DirSource{Dir: "/srv/templates"}},
+		{"git:/srv/gitignore", &GitSource{Dir: "/srv/gitignore"}},
+		{"git:/srv/gitignore@v2", &GitSource{Dir: "/srv/gitignore", Ref: "v2"}},
+		{"tarball:https://example.com/t.tar.gz", &TarballSource{URL: "https://example.com/t.tar.gz", Client: http.DefaultClient}},
+	}
+
+	for _, test := range tests {
+		source, err := ParseSource(test.spec, defaultUpstream, http.DefaultClient)
+		if err != nil {
+			t.Errorf("ParseSource(%q) returned error: %v", test.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(source, test.expected) {
+			t.Errorf("ParseSource(%q) = %#v, expected %#v", test.spec, source, test.expected)
+		}
+	}
+
+	for _, spec := range []string{"dir:", "svn:/srv/templates", "templates"} {
+		if _, err := ParseSource(spec, defaultUpstream, http.DefaultClient); err == nil {
+			t.Errorf("Expected an error for source %q", spec)
+		}
+	}
+}
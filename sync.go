@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// archiveThreshold is the number of templates to download above which one
+// archive of the source is faster than fetching them one by one
+const archiveThreshold = 20
+
+// syncTemplates brings the templates directory in line with the blob SHAs of
+// the source. Only templates that are missing or whose content differs are
+// downloaded, and downloads that do not match their SHA are rejected. With
+// prune, local templates the source does not have are removed.
+func (d *Downloader) syncTemplates(templatesDir string, hashes map[string]string, prune bool) error {
+	var changed []string
+	unchanged := 0
+	for path, sha := range hashes {
+		content, err := ioutil.ReadFile(filepath.Join(templatesDir, filepath.FromSlash(path)))
+		if err == nil && gitBlobSHA(content) == sha {
+			unchanged++
+			continue
+		}
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+
+	// Many templates are faster to take from one archive, if it can be
+	// downloaded
+	source := d.Source
+	if archiver, ok := source.(archiver); ok && len(changed) > archiveThreshold {
+		archive := archiver.Archive()
+		if _, err := archive.List(); err != nil {
+			fmt.Printf("Warning: cannot use the archive, downloading the templates one by one: %v\n", err)
+		} else {
+			source = archive
+		}
+	}
+
+	downloaded, failed := 0, 0
+	for _, path := range changed {
+		content, err := source.Fetch(path)
+		if err == nil && gitBlobSHA(content) != hashes[path] {
+			err = fmt.Errorf("content does not match SHA %s", hashes[path])
+		}
+		if err == nil {
+			err = saveTemplate(templatesDir, path, content)
+		}
+		if err != nil {
+			fmt.Printf("Warning: failed to download %s: %v\n", path, err)
+			failed++
+			continue
+		}
+
+		// Give some feedback on progress
+		fmt.Printf("Downloaded %s\n", path)
+		downloaded++
+	}
+
+	removed := 0
+	if prune {
+		local, err := (&DirSource{Dir: templatesDir}).List()
+		if err != nil {
+			return err
+		}
+		for _, path := range local {
+			if _, ok := hashes[path]; ok {
+				continue
+			}
+			file := filepath.Join(templatesDir, filepath.FromSlash(path))
+			err = os.Remove(file)
+			if err != nil {
+				return fmt.Errorf("error removing %s: %v", path, err)
+			}
+			removed++
+
+			// Remove directories left empty
+			for dir := filepath.Dir(file); dir != templatesDir; dir = filepath.Dir(dir) {
+				if os.Remove(dir) != nil {
+					break
+				}
+			}
+		}
+	}
+
+	fmt.Printf("%d templates downloaded, %d up to date, %d removed\n", downloaded, unchanged, removed)
+	if failed > 0 {
+		return fmt.Errorf("%d templates could not be downloaded", failed)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGitBlobSHA tests that blob SHAs match the ones git computes
+func TestGitBlobSHA(t *testing.T) {
+	// git hash-object of an empty file and of "hello\n"
+	if sha := gitBlobSHA(nil); sha != "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391" {
+		t.Errorf("Unexpected SHA %s for an empty file", sha)
+	}
+	if sha := gitBlobSHA([]byte("hello\n")); sha != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("Unexpected SHA %s for hello", sha)
+	}
+}
+
+// TestSyncTemplates tests that only changed templates are downloaded and
+// that templates removed upstream are removed locally
+func TestSyncTemplates(t *testing.T) {
+	upstream := map[string]string{
+		"Go.gitignore":                       "# Go\n",
+		"Global/macOS.gitignore":             "# macOS, new version\n",
+		"community/JavaScript/Vue.gitignore": "# Vue\n",
+		"Corrupt.gitignore":                  "# Corrupt\n",
+	}
+
+	var entries []string
+	for path, content := range upstream {
+		entries = append(entries, fmt.Sprintf(`{"path": %q, "type": "blob", "sha": %q}`, path, gitBlobSHA([]byte(content))))
+	}
+	tree := `{"sha": "abc", "truncated": false, "tree": [` + strings.Join(entries, ",") + `]}`
+
+	var downloads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/github/gitignore/git/trees/main" {
+			w.Write([]byte(tree))
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/github/gitignore/main/")
+		if content, ok := upstream[path]; ok {
+			downloads = append(downloads, path)
+			if path == "Corrupt.gitignore" {
+				content = "# truncated"
+			}
+			w.Write([]byte(content))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	tempDir, err := ioutil.TempDir("", "gitignore-sync-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Go is up to date, macOS is outdated and Old was removed upstream
+	writeFiles(t, tempDir, map[string]string{
+		"Go.gitignore":           "# Go\n",
+		"Global/macOS.gitignore": "# macOS\n",
+		"Old.gitignore":          "# Old\n",
+		"config.json":            "{}\n",
+		".snapshots/abc":         "# snapshot\n",
+	})
+
+	downloader := &Downloader{Source: NewGitHubSource(defaultUpstream.Override(Upstream{APIURL: server.URL, RawURL: server.URL}), server.Client())}
+	// The corrupt template makes the download fail after the others
+	err = downloader.downloadTemplates(tempDir, true)
+	if err == nil {
+		t.Error("Expected an error for the template not matching its SHA")
+	}
+
+	expectedDownloads := "Corrupt.gitignore Global/macOS.gitignore community/JavaScript/Vue.gitignore"
+	if got := strings.Join(downloads, " "); got != expectedDownloads {
+		t.Errorf("Expected downloads %s, got %s", expectedDownloads, got)
+	}
+
+	for path, expected := range map[string]string{
+		"Go.gitignore":                       "# Go\n",
+		"Global/macOS.gitignore":             "# macOS, new version\n",
+		"community/JavaScript/Vue.gitignore": "# Vue\n",
+		"config.json":                        "{}\n",
+		".snapshots/abc":                     "# snapshot\n",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(tempDir, filepath.FromSlash(path)))
+		if err != nil || string(content) != expected {
+			t.Errorf("Expected %s to contain %q, got %q (%v)", path, expected, content, err)
+		}
+	}
+
+	// A download not matching its SHA is not saved
+	for _, path := range []string{"Old.gitignore", "Corrupt.gitignore"} {
+		if _, err := os.Stat(filepath.Join(tempDir, path)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to exist", path)
+		}
+	}
+
+	// Without replace, local templates are kept
+	writeFiles(t, tempDir, map[string]string{"Old.gitignore": "# Old\n"})
+	downloads = nil
+	err = downloader.DownloadTemplates(tempDir)
+	if err == nil {
+		t.Error("Expected an error for the template not matching its SHA")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "Old.gitignore")); err != nil {
+		t.Error("Expected DownloadTemplates to keep Old.gitignore")
+	}
+	if got := strings.Join(downloads, " "); got != "Corrupt.gitignore" {
+		t.Errorf("Expected only the corrupt template to be downloaded again, got %s", got)
+	}
+}
+
+// TestSyncTemplatesArchiveFailure tests that an archive that cannot be
+// downloaded is tried once before falling back to single files
+func TestSyncTemplatesArchiveFailure(t *testing.T) {
+	upstream := make(map[string]string)
+	var entries []string
+	for i := 0; i < archiveThreshold+10; i++ {
+		path := fmt.Sprintf("Template%d.gitignore", i)
+		upstream[path] = fmt.Sprintf("# %d\n", i)
+		entries = append(entries, fmt.Sprintf(`{"path": %q, "type": "blob", "sha": %q}`, path, gitBlobSHA([]byte(upstream[path]))))
+	}
+	tree := `{"sha": "abc", "truncated": false, "tree": [` + strings.Join(entries, ",") + `]}`
+
+	archiveRequests, fileRequests := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/github/gitignore/git/trees/main":
+			w.Write([]byte(tree))
+		case r.URL.Path == "/repos/github/gitignore/tarball/main":
+			archiveRequests++
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			fileRequests++
+			w.Write([]byte(upstream[strings.TrimPrefix(r.URL.Path, "/github/gitignore/main/")]))
+		}
+	}))
+	defer server.Close()
+
+	tempDir, err := ioutil.TempDir("", "gitignore-sync-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	downloader := &Downloader{Source: NewGitHubSource(defaultUpstream.Override(Upstream{APIURL: server.URL, RawURL: server.URL}), server.Client())}
+	err = downloader.DownloadTemplates(tempDir)
+	if err != nil {
+		t.Fatalf("DownloadTemplates returned error: %v", err)
+	}
+
+	if archiveRequests != 1 {
+		t.Errorf("Expected the archive to be requested once, got %d requests", archiveRequests)
+	}
+	if fileRequests != len(upstream) {
+		t.Errorf("Expected %d single file downloads, got %d", len(upstream), fileRequests)
+	}
+	if paths, _ := (&DirSource{Dir: tempDir}).List(); len(paths) != len(upstream) {
+		t.Errorf("Expected %d templates, got %d", len(upstream), len(paths))
+	}
+}
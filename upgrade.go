@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// snapshotsDirName is the directory inside the templates directory that keeps
+// the content originally written to each managed block, keyed by its hash
+const snapshotsDirName = ".snapshots"
+
+// UpgradeStatus describes what happened to a managed block during an upgrade
+type UpgradeStatus string
+
+const (
+	// UpgradeUnchanged means the block already matched its template
+	UpgradeUnchanged UpgradeStatus = "up to date"
+	// UpgradeUpdated means the block was rewritten with the new template
+	UpgradeUpdated UpgradeStatus = "updated"
+	// UpgradeMerged means the new template was merged with local edits
+	UpgradeMerged UpgradeStatus = "updated, local edits kept"
+	// UpgradeConflict means local edits and the new template changed the
+	// same lines; the block now contains conflict markers
+	UpgradeConflict UpgradeStatus = "conflict, resolve the markers by hand"
+	// UpgradeMissing means the template is not in the local cache, so the
+	// block was left alone
+	UpgradeMissing UpgradeStatus = "template not found, kept as is"
+	// UpgradeBaseUnknown means the block was edited locally but the content
+	// originally written is not among the snapshots, e.g. because it was
+	// written on another machine, so the block was left alone
+	UpgradeBaseUnknown UpgradeStatus = "base unknown, kept as is"
+)
+
+// UpgradeResult is the outcome of upgrading a single managed block
+type UpgradeResult struct {
+	Name   string
+	Status UpgradeStatus
+}
+
+// UpgradeGitignore re-reads the template of every managed block in the file
+// from the local templates and rewrites the blocks whose content changed.
+// Blocks that were edited locally are three-way merged between the content
+// originally written, the edited block and the new template, or left alone
+// if the snapshot of the original content is missing. Blocks with a
+// preset's extra lines are left alone. The file is only written if at least
+// one block changed.
+func UpgradeGitignore(templates *Templates, path string) ([]UpgradeResult, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ParseGitignoreFile(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file: %v", err)
+	}
+
+	var results []UpgradeResult
+	changed := false
+	unmanaged := file.Unmanaged()
+	for _, block := range file.Blocks() {
+		// Preset extra lines have no template to upgrade from
+		if strings.HasPrefix(block.Name, presetBlockPrefix) {
+			continue
+		}
+
+		template, found := templates.GetTemplate(block.Name)
+		if !found {
+			results = append(results, UpgradeResult{Name: block.Label(), Status: UpgradeMissing})
+			continue
+		}
+
+		newContent := normalizeBlockContent(missingLines(unmanaged, RebaseRules(template, block.Under)))
+		newBase, err := saveSnapshot(newContent)
+		if err != nil {
+			return nil, fmt.Errorf("error saving snapshot: %v", err)
+		}
+
+		if newContent == block.Content {
+			// Record the base for blocks written before snapshots existed
+			if block.Base != newBase {
+				block.Base = newBase
+				changed = true
+			}
+			results = append(results, UpgradeResult{Name: block.Label(), Status: UpgradeUnchanged})
+			continue
+		}
+
+		status := UpgradeUpdated
+		merged := newContent
+		if edited := block.Base != "" && contentHash(block.Content) != block.Base; edited {
+			// Without the original content there is nothing to tell the
+			// local edits from the template's own lines
+			base, found := loadSnapshot(block.Base)
+			if !found {
+				results = append(results, UpgradeResult{Name: block.Label(), Status: UpgradeBaseUnknown})
+				continue
+			}
+
+			var conflict bool
+			merged, conflict = Merge3(base, block.Content, newContent)
+			status = UpgradeMerged
+			if conflict {
+				status = UpgradeConflict
+			}
+		}
+
+		block.Content = merged
+		block.Base = newBase
+		changed = true
+		results = append(results, UpgradeResult{Name: block.Label(), Status: status})
+	}
+
+	if changed {
+		err = ioutil.WriteFile(path, []byte(file.String()), 0644)
+		if err != nil {
+			return nil, fmt.Errorf("error writing file: %v", err)
+		}
+	}
+
+	return results, nil
+}
+
+// contentHash returns the short hash used to identify block content
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// saveSnapshot stores block content in the snapshots directory and returns
+// its hash
+func saveSnapshot(content string) (string, error) {
+	templatesDir, err := getTemplatesDir()
+	if err != nil {
+		return "", err
+	}
+
+	snapshotsDir := filepath.Join(templatesDir, snapshotsDirName)
+	err = os.MkdirAll(snapshotsDir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	hash := contentHash(content)
+	err = ioutil.WriteFile(filepath.Join(snapshotsDir, hash), []byte(content), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// loadSnapshot returns the block content stored under the given hash
+func loadSnapshot(hash string) (string, bool) {
+	templatesDir, err := getTemplatesDir()
+	if err != nil {
+		return "", false
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(templatesDir, snapshotsDirName, hash))
+	if err != nil {
+		return "", false
+	}
+
+	return string(content), true
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestUpgradeGitignore tests refreshing managed blocks from the templates
+func TestUpgradeGitignore(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-upgrade-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
+
+	outputPath := filepath.Join(tempDir, ".gitignore")
+	content := "/mine\n\n" +
+		"# >>> getignore: Go\n*.exe\n# <<< getignore: Go\n\n" +
+		"# >>> getignore: Node\nnode_modules/\n# <<< getignore: Node\n\n" +
+		"# >>> getignore: Unknown\n*.tmp\n# <<< getignore: Unknown\n\n" +
+		"# >>> getignore: preset:backend\n*.env\n# <<< getignore: preset:backend\n"
+	err = ioutil.WriteFile(outputPath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	templates := NewTemplates()
+	templates.templates["Go"] = "*.exe\n*.test\n"
+	templates.templates["Node"] = "node_modules/\n"
+
+	results, err := UpgradeGitignore(templates, outputPath)
+	if err != nil {
+		t.Fatalf("UpgradeGitignore returned error: %v", err)
+	}
+
+	expectedResults := []UpgradeResult{
+		{Name: "Go", Status: UpgradeUpdated},
+		{Name: "Node", Status: UpgradeUnchanged},
+		{Name: "Unknown", Status: UpgradeMissing},
+	}
+	if len(results) != len(expectedResults) {
+		t.Fatalf("Expected %d results, got %d", len(expectedResults), len(results))
+	}
+	for i, result := range results {
+		if result != expectedResults[i] {
+			t.Errorf("Result %d: expected %+v, got %+v", i, expectedResults[i], result)
+		}
+	}
+
+	upgraded, err := ioutil.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	expected := "/mine\n\n" +
+		"# >>> getignore: Go base=" + contentHash("*.exe\n*.test\n") + "\n*.exe\n*.test\n# <<< getignore: Go\n\n" +
+		"# >>> getignore: Node base=" + contentHash("node_modules/\n") + "\nnode_modules/\n# <<< getignore: Node\n\n" +
+		"# >>> getignore: Unknown\n*.tmp\n# <<< getignore: Unknown\n\n" +
+		"# >>> getignore: preset:backend\n*.env\n# <<< getignore: preset:backend\n"
+	if string(upgraded) != expected {
+		t.Errorf("Upgraded content mismatch. Expected '%s', got '%s'", expected, string(upgraded))
+	}
+}
+
+// TestUpgradeGitignoreLocalEdits tests that local edits inside a block are
+// merged with the new template instead of being overwritten
+func TestUpgradeGitignoreLocalEdits(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gitignore-upgrade-edit-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	t.Setenv("HOME", tempDir)
+
+	outputPath := filepath.Join(tempDir, ".gitignore")
+	sections := []TemplateSection{{Name: "Go", Content: "*.exe\n*.dll\n*.so\n"}}
+	err = WriteGitignore(sections, outputPath, WriteOverwrite)
+	if err != nil {
+		t.Fatalf("WriteGitignore returned error: %v", err)
+	}
+
+	// Edit a line inside the block
+	content, err := ioutil.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	edited := strings.Replace(string(content), "*.dll\n", "*.dll\n!keep.dll\n", 1)
+	err = ioutil.WriteFile(outputPath, []byte(edited), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Upstream adds a line in a different place
+	templates := NewTemplates()
+	templates.templates["Go"] = "*.exe\n*.dll\n*.so\n*.dylib\n"
+
+	results, err := UpgradeGitignore(templates, outputPath)
+	if err != nil {
+		t.Fatalf("UpgradeGitignore returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].Status != UpgradeMerged {
+		t.Fatalf("Expected a merged result, got %+v", results)
+	}
+
+	file := readGitignoreFile(t, outputPath)
+	block, _ := file.Block("Go", "")
+	if block.Content != "*.exe\n*.dll\n!keep.dll\n*.so\n*.dylib\n" {
+		t.Errorf("Unexpected merged content '%s'", block.Content)
+	}
+
+	// Now both sides change the same line. The new base is the upgraded
+	// template, so the local edits form a single conflicting region.
+	file.SetBlock("Go", "", strings.Replace(block.Content, "*.so\n", "*.so.1\n", 1))
+	err = ioutil.WriteFile(outputPath, []byte(file.String()), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	templates.templates["Go"] = "*.exe\n*.dll\n*.so.*\n*.dylib\n"
+
+	results, err = UpgradeGitignore(templates, outputPath)
+	if err != nil {
+		t.Fatalf("UpgradeGitignore returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].Status != UpgradeConflict {
+		t.Fatalf("Expected a conflict result, got %+v", results)
+	}
+
+	block, _ = readGitignoreFile(t, outputPath).Block("Go", "")
+	expected := "*.exe\n*.dll\n<<<<<<< local\n!keep.dll\n*.so.1\n=======\n*.so.*\n>>>>>>> upstream\n*.dylib\n"
+	if block.Content != expected {
+		t.Errorf("Unexpected conflict content. Expected '%s', got '%s'", expected, block.Content)
+	}
+
+	// On a machine without the snapshots an edited block is left alone
+	// instead of turning into one big conflict
+	file.SetBlock("Go", "", "*.exe\n!keep.exe\n")
+	err = ioutil.WriteFile(outputPath, []byte(file.String()), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	err = os.RemoveAll(filepath.Join(tempDir, ".gitignore-cli", snapshotsDirName))
+	if err != nil {
+		t.Fatalf("Failed to remove snapshots: %v", err)
+	}
+	before := file.String()
+
+	results, err = UpgradeGitignore(templates, outputPath)
+	if err != nil {
+		t.Fatalf("UpgradeGitignore returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].Status != UpgradeBaseUnknown {
+		t.Fatalf("Expected an unknown base result, got %+v", results)
+	}
+	if after := readGitignoreFile(t, outputPath).String(); after != before {
+		t.Errorf("Expected the file to be unchanged, got '%s'", after)
+	}
+}
+
+// readGitignoreFile reads and parses a gitignore file for a test
+func readGitignoreFile(t *testing.T, path string) *GitignoreFile {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	file, err := ParseGitignoreFile(string(content))
+	if err != nil {
+		t.Fatalf("ParseGitignoreFile returned error: %v", err)
+	}
+	return file
+}